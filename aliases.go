@@ -0,0 +1,212 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/teghnet/x/paths"
+)
+
+// ErrAliasRecursion is returned when an alias expands, directly or not, to itself.
+var ErrAliasRecursion = errors.New("alias recursion")
+
+// AliasOption customizes where [Aliases] and [ListAliases] read the aliases from.
+type AliasOption func(*aliasConfig)
+
+// AliasFile sets the file with the aliases. By default, it is "aliases.json" under the ConfigPath.
+func AliasFile(name string) AliasOption {
+	return func(c *aliasConfig) { c.file = name }
+}
+
+// AliasXDG sets the app directories. By default, those of the [app.App] the context was derived from are used.
+func AliasXDG(xdg paths.XDG) AliasOption {
+	return func(c *aliasConfig) { c.xdg = xdg }
+}
+
+type aliasConfig struct {
+	file string
+	xdg  paths.XDG
+}
+
+// Aliases returns a CommandSelector expanding the aliases defined by the user before consulting cs.
+//
+// The aliases are a JSON object mapping names to a command line (a string split with [ShellSplit]),
+// to the arguments of a command or to a list of those, run one after another (a macro):
+//
+//	{
+//	  "daily": ["batch", "-i", "~/daily.jsonl"],
+//	  "ls": "ls -l",
+//	  "release": [["test", "./..."], ["build", "-o", "dist"]]
+//	}
+//
+// Arguments given to an alias are appended to it or, for a macro, to its last step. A leading "~" is the home directory.
+// The expansion may be "batch", "shell" or another alias. An alias referring to itself in the first word
+// means the command of that name; an alias reached again through other aliases fails with [ErrAliasRecursion].
+func Aliases(cs CommandSelector, opts ...AliasOption) CommandSelector {
+	var conf aliasConfig
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	var self CommandSelector
+	self = func(name string, args []string) Command {
+		return func(ctx context.Context) error {
+			chain, _ := ctx.Value(aliasKey{}).([]string)
+			if len(chain) > 0 && chain[len(chain)-1] == name {
+				return selectCommand(cs, name, args)(ctx)
+			}
+			aliases, err := conf.load(ctx)
+			if err != nil {
+				return err
+			}
+			steps, ok := aliases[name]
+			if !ok {
+				return selectCommand(cs, name, args)(ctx)
+			}
+			if slices.Contains(chain, name) {
+				return fmt.Errorf("%w: %s -> %s", ErrAliasRecursion, strings.Join(chain, " -> "), name)
+			}
+			ctx = context.WithValue(ctx, aliasKey{}, append(slices.Clip(chain), name))
+			for i, step := range steps {
+				step = expandHome(step)
+				if i == len(steps)-1 {
+					step = append(step, args...)
+				}
+				if err := BatchOnce(step, self)(ctx); err != nil {
+					return fmt.Errorf("alias %s: %w", name, err)
+				}
+			}
+			return nil
+		}
+	}
+	return self
+}
+
+// aliasKey is the context key of the aliases being expanded.
+type aliasKey struct{}
+
+// ListAliases returns the handler of a command printing the aliases:
+//
+//	reg.Handle("aliases", x.ListAliases(), x.CommandShort("list the aliases"))
+func ListAliases(opts ...AliasOption) Handler {
+	var conf aliasConfig
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	return func(args []string) Command {
+		return func(ctx context.Context) error {
+			if err := FlagsParse(args); err != nil {
+				return err
+			}
+			aliases, err := conf.load(ctx)
+			if err != nil {
+				return err
+			}
+			names := make([]string, 0, len(aliases))
+			for name := range aliases {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			tw := tabwriter.NewWriter(Stdout(ctx), 0, 4, 2, ' ', 0)
+			for _, name := range names {
+				lines := make([]string, len(aliases[name]))
+				for i, step := range aliases[name] {
+					lines[i] = ShellJoin(step...)
+				}
+				if _, err := fmt.Fprintf(tw, "%s\t%s\n", name, strings.Join(lines, "; ")); err != nil {
+					return err
+				}
+			}
+			return tw.Flush()
+		}
+	}
+}
+
+// load reads the aliases. A missing file means no aliases.
+func (c aliasConfig) load(ctx context.Context) (map[string]aliasSteps, error) {
+	name := c.file
+	if name == "" {
+		xdg, ok := appXDG(ctx, c.xdg)
+		if !ok {
+			return nil, nil
+		}
+		name = xdg.ConfigPath("aliases.json")
+	}
+	b, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var aliases map[string]aliasSteps
+	if err := json.Unmarshal(b, &aliases); err != nil {
+		return nil, fmt.Errorf("aliases %s: %w", name, err)
+	}
+	for n, steps := range aliases {
+		if len(steps) == 0 || slices.ContainsFunc(steps, func(s []string) bool { return len(s) == 0 }) {
+			return nil, fmt.Errorf("aliases %s: empty command in alias %q", name, n)
+		}
+	}
+	return aliases, nil
+}
+
+// aliasSteps are the commands an alias expands to.
+type aliasSteps [][]string
+
+// UnmarshalJSON accepts a command line, a list of arguments or a list of either.
+func (s *aliasSteps) UnmarshalJSON(b []byte) error {
+	var line string
+	if err := json.Unmarshal(b, &line); err == nil {
+		args, err := ShellSplit(line)
+		*s = aliasSteps{args}
+		return err
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return fmt.Errorf("alias must be a string or an array: %s", b)
+	}
+	var args []string
+	if err := json.Unmarshal(b, &args); err == nil {
+		*s = aliasSteps{args}
+		return nil
+	}
+	*s = make(aliasSteps, len(raw))
+	for i, r := range raw {
+		var step aliasSteps
+		if err := step.UnmarshalJSON(r); err != nil {
+			return err
+		}
+		if len(step) != 1 {
+			return fmt.Errorf("macro steps must be commands: %s", r)
+		}
+		(*s)[i] = step[0]
+	}
+	return nil
+}
+
+// expandHome replaces a leading "~" of the arguments with the home directory.
+func expandHome(args []string) []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return args
+	}
+	out := make([]string, len(args))
+	for i, a := range args {
+		if a == "~" || strings.HasPrefix(a, "~/") {
+			a = filepath.Join(home, a[1:])
+		}
+		out[i] = a
+	}
+	return out
+}
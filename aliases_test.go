@@ -0,0 +1,80 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/teghnet/x"
+)
+
+func TestAliases(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	file := filepath.Join(t.TempDir(), "aliases.json")
+	err := os.WriteFile(file, []byte(`{
+		"ls": "ls -l",
+		"ll": ["ls", "-a"],
+		"daily": ["batch", "-format", "shell", "-i", "~/daily.txt"],
+		"release": [["test", "./..."], "build -o 'dist dir'"],
+		"loop": "again",
+		"again": ["loop"]
+	}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, "daily.txt"), []byte("ll x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var got [][]string
+	cs := func(name string, args []string) x.Command {
+		return func(context.Context) error {
+			got = append(got, append([]string{name}, args...))
+			return nil
+		}
+	}
+	aliases := x.Aliases(cs, x.AliasFile(file))
+
+	tests := []struct {
+		args []string
+		want [][]string
+	}{
+		{[]string{"ls", "dir"}, [][]string{{"ls", "-l", "dir"}}},
+		{[]string{"ll"}, [][]string{{"ls", "-l", "-a"}}},
+		{[]string{"daily"}, [][]string{{"ls", "-l", "-a", "x"}}},
+		{[]string{"release", "-v"}, [][]string{{"test", "./..."}, {"build", "-o", "dist dir", "-v"}}},
+		{[]string{"other"}, [][]string{{"other"}}},
+	}
+	for _, tt := range tests {
+		got = nil
+		if err := x.BatchOnce(tt.args, aliases)(t.Context()); err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		if !slices.EqualFunc(got, tt.want, slices.Equal) {
+			t.Errorf("%q: ran %q, want %q", tt.args, got, tt.want)
+		}
+	}
+
+	err = x.BatchOnce([]string{"loop"}, aliases)(t.Context())
+	if !errors.Is(err, x.ErrAliasRecursion) || !strings.Contains(err.Error(), "loop -> again -> loop") {
+		t.Errorf("err = %v, want alias recursion", err)
+	}
+
+	var out bytes.Buffer
+	if err := x.ListAliases(x.AliasFile(file))(nil)(x.WithStdout(t.Context(), &out)); err != nil {
+		t.Fatal(err)
+	}
+	if want := "release  test ./...; build -o 'dist dir'\n"; !strings.Contains(out.String(), want) {
+		t.Errorf("aliases output:\n%s\nwant line %q", out.String(), want)
+	}
+}
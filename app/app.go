@@ -64,9 +64,20 @@ func (a *App) Err() error {
 
 // Value implements [context.Context]
 func (a *App) Value(key any) any {
+	if key == (appKey{}) {
+		return a
+	}
 	return a.ctx.Value(key)
 }
 
+type appKey struct{}
+
+// FromContext returns the App the context was derived from.
+func FromContext(ctx context.Context) (*App, bool) {
+	a, ok := ctx.Value(appKey{}).(*App)
+	return a, ok
+}
+
 // [paths.XDG]
 
 // CachePath implements [paths.XDG]
@@ -0,0 +1,218 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"charm.land/log/v2"
+	"github.com/teghnet/x/app"
+	"github.com/teghnet/x/paths"
+)
+
+// AuditOption customizes the [Audit] middleware.
+type AuditOption func(*auditConfig)
+
+// AuditFile sets the audit log. By default, it is "audit.jsonl" under the StatePath.
+func AuditFile(name string) AuditOption {
+	return func(c *auditConfig) { c.file = name }
+}
+
+// AuditState sets where the audit log is kept by default. By default, the [app.App] the context was derived from is used.
+func AuditState(xdg paths.XDG) AuditOption {
+	return func(c *auditConfig) { c.state = xdg }
+}
+
+// AuditRotate sets the size above which the audit log is rotated and how many rotated files are kept
+// (by default 10 MiB and 5). The rotated files get the suffixes ".1" (the newest) to ".<backups>".
+func AuditRotate(maxSize int64, backups int) AuditOption {
+	return func(c *auditConfig) { c.maxSize, c.backups = maxSize, backups }
+}
+
+// AuditRedact adds names of flags whose values are replaced with "REDACTED" in the audit log.
+// Flags with a part of the name (separated by dashes, underscores or dots) like pass, password, secret,
+// token, key, apikey or credential are always redacted.
+func AuditRedact(flags ...string) AuditOption {
+	return func(c *auditConfig) { c.redact = append(c.redact, flags...) }
+}
+
+// AuditCommands makes the flags of the commands of the registry, described with [CommandFlags], known
+// to the audit log. The argument following a secret flag given without "=" is then redacted only if
+// the flag takes a value; for unknown flags it always is.
+func AuditCommands(reg *Registry) AuditOption {
+	return func(c *auditConfig) { c.commands = reg }
+}
+
+type auditConfig struct {
+	file     string
+	state    paths.XDG
+	maxSize  int64
+	backups  int
+	redact   []string
+	commands *Registry
+}
+
+// auditRecord is the line written to the audit log for each command.
+type auditRecord struct {
+	Time     time.Time `json:"time"`
+	User     string    `json:"user,omitempty"`
+	Dir      string    `json:"dir,omitempty"`
+	App      string    `json:"app,omitempty"`
+	Name     string    `json:"name"`
+	Args     []string  `json:"args"`
+	Duration string    `json:"duration"`
+	Status   string    `json:"status"`
+	ExitCode int       `json:"exit_code"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// auditMu serializes writes of the audit logs of the process.
+var auditMu sync.Mutex
+
+// Audit returns a middleware appending a JSONL record to the audit log for every command:
+// the time, the user, the working directory, the app name, the command name and its arguments,
+// the duration and the result. Values of secret flags are redacted, see [AuditRedact] and [AuditCommands].
+//
+// Use it with [WrapSelector], so commands run by [Batch] are audited too.
+// Failing to write the log is logged but does not fail the command.
+func Audit(opts ...AuditOption) Middleware {
+	conf := auditConfig{maxSize: 10 << 20, backups: 5}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	return func(cmd Command) Command {
+		return func(ctx context.Context) error {
+			start := time.Now()
+			err := cmd(ctx)
+			inv, _ := InvocationFrom(ctx)
+			rec := auditRecord{
+				Time:     start,
+				User:     auditUser(),
+				Name:     inv.Name,
+				Args:     conf.redactArgs(inv),
+				Duration: time.Since(start).String(),
+				Status:   "ok",
+				ExitCode: ExitCode(err),
+			}
+			rec.Dir, _ = os.Getwd()
+			if a, ok := app.FromContext(ctx); ok {
+				rec.App = a.Name
+			}
+			if err != nil {
+				rec.Status, rec.Error = "failed", err.Error()
+			}
+			if werr := conf.write(ctx, rec); werr != nil {
+				log.Warn("could not write the audit log", "err", werr)
+			}
+			return err
+		}
+	}
+}
+
+// auditUser returns the name of the user running the process.
+var auditUser = sync.OnceValue(func() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+})
+
+// secretFlag matches names of flags redacted by default, by whole parts of the name.
+var secretFlag = regexp.MustCompile(`(?i)(^|[-_.])(pass(word|wd|phrase)?|secrets?|tokens?|(api)?keys?|credentials?)([-_.]|$)`)
+
+// redactArgs returns the arguments of the invocation with the values of secret flags replaced,
+// whether given as -name=value or as -name value.
+func (c auditConfig) redactArgs(inv Invocation) []string {
+	var flags *flag.FlagSet
+	if c.commands != nil {
+		flags = c.commands.flagSetOf(inv.Name, inv.Args)
+	}
+	out := slices.Clone(inv.Args)
+	for i := 0; i < len(out); i++ {
+		a := out[i]
+		if !strings.HasPrefix(a, "-") || a == "-" || a == "--" {
+			continue
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		if !secretFlag.MatchString(name) && !slices.Contains(c.redact, name) {
+			continue
+		}
+		if hasValue {
+			out[i] = a[:strings.IndexByte(a, '=')+1] + "REDACTED"
+			continue
+		}
+		if flags != nil {
+			if f := flags.Lookup(name); f != nil && isBoolFlag(f) {
+				continue
+			}
+		}
+		if i+1 < len(out) {
+			i++
+			out[i] = "REDACTED"
+		}
+	}
+	return out
+}
+
+func (c auditConfig) write(ctx context.Context, rec auditRecord) error {
+	name := c.file
+	if name == "" {
+		xdg, ok := appXDG(ctx, c.state)
+		if !ok {
+			return errors.New("no audit file and no app in the context")
+		}
+		name = xdg.StatePath("audit.jsonl")
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return err
+	}
+	if info, err := os.Stat(name); err == nil && c.maxSize > 0 && info.Size()+int64(len(b)) > c.maxSize {
+		if err := rotate(name, c.backups); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// rotate renames name to name.1, name.1 to name.2 and so on, dropping the oldest beyond backups.
+func rotate(name string, backups int) error {
+	if backups < 1 {
+		return os.Remove(name)
+	}
+	for i := backups - 1; i > 0; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", name, i), fmt.Sprintf("%s.%d", name, i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(name, name+".1")
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/teghnet/x"
+)
+
+func TestAudit(t *testing.T) {
+	xdg := testXDG(t.TempDir())
+	cs := func(name string, args []string) x.Command {
+		return func(context.Context) error {
+			if name == "fail" {
+				return errors.New("boom")
+			}
+			return nil
+		}
+	}
+	reg := x.NewRegistry("tool")
+	reg.Handle("login", func([]string) x.Command { return nil }, x.CommandFlags(func() []x.FlagOption {
+		var user, password string
+		var skipTokens bool
+		return []x.FlagOption{
+			x.Flag(&user, "user", "user name"),
+			x.Flag(&password, "password", "password"),
+			x.Flag(&skipTokens, "skip-tokens", "do not refresh tokens"),
+		}
+	}))
+	wrapped := x.WrapSelector(cs, x.Audit(x.AuditState(xdg), x.AuditRedact("dsn"), x.AuditRotate(100, 1), x.AuditCommands(reg)))
+
+	if err := x.BatchOnce([]string{"login", "-user", "bob", "-password", "hunter2", "--api-token=abc", "-dsn=pg://",
+		"-keyboard", "us", "-skip-tokens", "notes.txt", "-secret", "s3"}, wrapped)(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.BatchOnce([]string{"fail"}, wrapped)(t.Context()); err == nil {
+		t.Fatal("want error")
+	}
+
+	type record struct {
+		Name     string   `json:"name"`
+		Args     []string `json:"args"`
+		Status   string   `json:"status"`
+		ExitCode int      `json:"exit_code"`
+		Error    string   `json:"error"`
+		Dir      string   `json:"dir"`
+	}
+	read := func(name string) []record {
+		t.Helper()
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer x.ClosePrint(f)
+		var recs []record
+		for dec := json.NewDecoder(f); dec.More(); {
+			var r record
+			if err := dec.Decode(&r); err != nil {
+				t.Fatal(err)
+			}
+			recs = append(recs, r)
+		}
+		return recs
+	}
+	log := xdg.StatePath("audit.jsonl")
+	recs := append(read(log+".1"), read(log)...)
+	if len(recs) != 2 {
+		t.Fatalf("got %d records, want 2 (one rotated)", len(recs))
+	}
+	want := []string{"-user", "bob", "-password", "REDACTED", "--api-token=REDACTED", "-dsn=REDACTED",
+		"-keyboard", "us", "-skip-tokens", "notes.txt", "-secret", "REDACTED"}
+	if recs[0].Name != "login" || !slices.Equal(recs[0].Args, want) || recs[0].Status != "ok" || recs[0].Dir == "" {
+		t.Errorf("first record = %+v, want args %q", recs[0], want)
+	}
+	if recs[1].Status != "failed" || recs[1].Error != "boom" || recs[1].ExitCode != x.ExitFailure {
+		t.Errorf("second record = %+v", recs[1])
+	}
+	if _, err := os.Stat(filepath.Join(string(xdg), "state", "audit.jsonl.2")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("only one backup should be kept: %v", err)
+	}
+}
@@ -0,0 +1,648 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"time"
+
+	"charm.land/log/v2"
+
+	"github.com/teghnet/x/fsio"
+	"github.com/teghnet/x/paths"
+)
+
+// BatchOption sets a default of [Batch]. Command-line flags given to the batch take precedence.
+type BatchOption func(*batchConfig)
+
+// BatchInput sets the batch input file (flag -i).
+func BatchInput(name string) BatchOption {
+	return func(c *batchConfig) { c.input = name }
+}
+
+// BatchFormat sets the format of the batch input (flag -format), see [BatchFormatJSON] and [BatchFormatShell].
+// By default, files with a .sh or .txt extension are read as shell-style lines and everything else as JSON.
+func BatchFormat(format string) BatchOption {
+	return func(c *batchConfig) { c.format = format }
+}
+
+// BatchContinue keeps the batch running when a command fails (flag -continue).
+func BatchContinue(keepGoing bool) BatchOption {
+	return func(c *batchConfig) { c.keepGoing = keepGoing }
+}
+
+// BatchJobs sets the number of commands run concurrently (flag -j).
+// Zero runs one command at a time, or GOMAXPROCS commands when the batch declares dependencies.
+func BatchJobs(n int) BatchOption {
+	return func(c *batchConfig) { c.jobs = n }
+}
+
+// BatchReport writes a JSONL record for every command and a final summary to the named file (flag -report).
+// The name is resolved with [DynamicWriter].
+func BatchReport(name string) BatchOption {
+	return func(c *batchConfig) { c.report = name }
+}
+
+// BatchTimeout limits the duration of every attempt of a command (flag -timeout).
+// Entries may override it with a "timeout" field.
+func BatchTimeout(d time.Duration) BatchOption {
+	return func(c *batchConfig) { c.timeout = d }
+}
+
+// BatchRetry sets the retry policy of failed commands (flags -retry, -retry-backoff, -retry-max-backoff and -retry-match).
+// Entries may override its fields with a "retry" object.
+func BatchRetry(p RetryPolicy) BatchOption {
+	return func(c *batchConfig) { c.retry = p }
+}
+
+// BatchResume skips the entries which succeeded in an earlier, failed run of the same input (flag -resume).
+// Progress is kept in a checkpoint file under the StatePath and removed once the whole batch succeeds.
+func BatchResume(resume bool) BatchOption {
+	return func(c *batchConfig) { c.resume = resume }
+}
+
+// BatchState sets where checkpoints are kept. By default, the [app.App] the context was derived from is used.
+func BatchState(xdg paths.XDG) BatchOption {
+	return func(c *batchConfig) { c.state = xdg }
+}
+
+// BatchVars sets values of ${NAME} placeholders in the batch arguments (flag -var name=value).
+// They take precedence over {"set":{...}} directives of the batch and over the environment.
+func BatchVars(vars map[string]string) BatchOption {
+	return func(c *batchConfig) { c.vars = vars }
+}
+
+// BatchStrict makes placeholders of undefined variables an error instead of an empty string (flag -strict).
+func BatchStrict(strict bool) BatchOption {
+	return func(c *batchConfig) { c.strict = strict }
+}
+
+// BatchOrdered buffers the output of concurrently run commands
+// and writes it in input order (flag -ordered).
+func BatchOrdered(ordered bool) BatchOption {
+	return func(c *batchConfig) { c.ordered = ordered }
+}
+
+type batchConfig struct {
+	input     string
+	format    string
+	keepGoing bool
+	jobs      int
+	ordered   bool
+	report    string
+	timeout   time.Duration
+	retry     RetryPolicy
+	resume    bool
+	state     paths.XDG
+	vars      varsFlag
+	strict    bool
+
+	// set by flags only
+	retryMatch       string
+	listCheckpoints  bool
+	clearCheckpoint  bool
+	clearCheckpoints bool
+}
+
+// BatchFlags describes the flags of [Batch], e.g. for [CommandFlags] when registering it under another name.
+func BatchFlags() []FlagOption {
+	conf := batchConfig{input: "-", vars: make(varsFlag)}
+	return conf.flags()
+}
+
+// flags declares the flags of the batch, bound to the config.
+func (c *batchConfig) flags() []FlagOption {
+	return []FlagOption{
+		Flag(&c.input, "i", "batch input file"),
+		FlagFile("i"),
+		Flag(&c.format, "format", "batch input format: json or shell (default: guessed from the -i extension)"),
+		FlagEnum("format", BatchFormatJSON, BatchFormatShell),
+		Flag(&c.keepGoing, "continue", "keep running even if there are errors"),
+		Flag(&c.jobs, "j", "number of commands to run concurrently (0: 1, or GOMAXPROCS for dependency graphs)"),
+		Flag(&c.ordered, "ordered", "keep output of concurrent commands in input order"),
+		Flag(&c.report, "report", "write a JSONL record per command and a summary to this file"),
+		FlagFile("report"),
+		Flag(&c.timeout, "timeout", "time limit of a single command attempt (0: none)"),
+		Flag(&c.retry.Attempts, "retry", "maximum number of attempts of a failing command"),
+		Flag(&c.retry.Backoff, "retry-backoff", "delay before the first retry, doubled after each one"),
+		Flag(&c.retry.MaxBackoff, "retry-max-backoff", "maximum delay between retries (0: unlimited)"),
+		Flag(&c.retryMatch, "retry-match", "retry only errors matching this regular expression"),
+		Flag(&c.resume, "resume", "skip entries which succeeded in the last run of this input"),
+		Flag(&c.listCheckpoints, "checkpoints", "list stored checkpoints and exit"),
+		Flag(&c.clearCheckpoint, "clear-checkpoint", "remove the checkpoint of this input and exit"),
+		Flag(&c.clearCheckpoints, "clear-checkpoints", "remove all stored checkpoints and exit"),
+		func(flags *flag.FlagSet) {
+			flags.Var(c.vars, "var", "set a variable as `name=value` (repeatable)")
+		},
+		Flag(&c.strict, "strict", "fail on undefined variables"),
+	}
+}
+
+// Batch returns a Command that runs every command read from the batch input.
+// The input is a stream of JSON string arrays or, in the shell format, one shell-style command per line.
+// Each command is passed to the CommandSelector. Commands run as they are read, so a batch may be fed by a pipe;
+// once an entry has an "id" or uses "needs", "if", "finally" or "on_error": "run:<id>", the rest of the input
+// is read and checked first.
+//
+// JSON entries may also be objects naming their dependencies: {"id":"fetch","args":[...],"needs":["init"]}.
+// Such a batch is a dependency graph: it is checked for cycles, unknown and duplicate ids before any of its
+// entries runs, independent branches run concurrently and entries whose dependencies failed are skipped.
+// As the input is streamed, plain entries preceding the first object with an "id" or "needs" are not
+// held back by the check and may already have run when it fails.
+// Objects may also set their own "timeout" and "retry" policy, see [RetryPolicy.UnmarshalJSON],
+// and send the output of the command to files: {"args":[...],"stdout":"out.log","stderr":"err.log","append":true}.
+// The command gets the files through [Stdout] and [Stderr].
+//
+// The flow of the batch may be changed per entry. "on_error" decides what a failure of the entry does:
+// "stop" stops the batch even with -continue, "continue" keeps it going and "run:<id>" keeps it going
+// and runs the entry with that id, an error handler which runs only then. An entry with "if": "previous_failed"
+// or "previous_succeeded" waits for the entry before it and is skipped unless the condition holds;
+// a failure followed by such a "previous_failed" entry does not stop the batch either.
+// The entries following {"finally": true}, or with "finally": true themselves, are cleanup steps:
+// they run one by one after all others, even when the batch was stopped or cancelled, each with a fresh
+// context limited to its own timeout or 30 seconds.
+//
+// Arguments may contain ${NAME} placeholders, filled from -var flags, from {"set":{"NAME":"value"}}
+// directives preceding them in the input and from the environment. Write $$ for a literal $.
+// In the shell format, placeholders in single quotes or after a backslash are kept literally, as in a shell.
+//
+// All commands share the context of the batch. With -j greater than 1 up to that many commands run concurrently;
+// without -continue the first failure in a batch without dependencies cancels the others and stops the batch.
+// The returned error joins the errors of all failed commands and, if the context was cancelled, its cause;
+// commands interrupted by the cancellation are reported as cancelled, not failed.
+func Batch(args []string, cs CommandSelector, opts ...BatchOption) Command {
+	return func(ctx context.Context) error {
+		conf := batchConfig{input: "-"}
+		for _, opt := range opts {
+			opt(&conf)
+		}
+		conf.vars = conf.vars.clone()
+		if conf.retry.Match != nil {
+			conf.retryMatch = conf.retry.Match.String()
+		}
+		err := FlagsParse(args, conf.flags()...)
+		if err != nil {
+			return err
+		}
+		if conf.retry.Match, err = compileOptional(conf.retryMatch); err != nil {
+			return fmt.Errorf("batch: -retry-match: %w", err)
+		}
+		if conf.jobs < 0 {
+			return fmt.Errorf("batch: -j must not be negative, got %d", conf.jobs)
+		}
+		if conf.listCheckpoints || conf.clearCheckpoint || conf.clearCheckpoints {
+			dir, err := conf.stateDir(ctx)
+			if err != nil {
+				return err
+			}
+			switch {
+			case conf.listCheckpoints:
+				return listBatchCheckpoints(Stdout(ctx), dir)
+			case conf.clearCheckpoints:
+				return fsio.Remove(dir, "*.jsonl")
+			}
+			err = os.Remove(filepath.Join(dir, batchCheckpointName(conf.input)))
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		f, err := DynamicReader(conf.input)
+		if err != nil {
+			return err
+		}
+		defer ClosePrint(f)
+		format, err := batchFormat(conf.format, conf.input)
+		if err != nil {
+			return err
+		}
+		rep, err := newBatchReport(conf.report)
+		if err != nil {
+			return err
+		}
+		defer ClosePrint(rep)
+		var ckpt *batchCheckpoint
+		if conf.resume {
+			dir, err := conf.stateDir(ctx)
+			if err != nil {
+				return err
+			}
+			if ckpt, err = openBatchCheckpoint(filepath.Join(dir, batchCheckpointName(conf.input))); err != nil {
+				return err
+			}
+			defer ClosePrint(ckpt)
+		}
+		if err := conf.run(ctx, cs, newBatchReader(f, format, &batchVars{flags: conf.vars, strict: conf.strict}), rep, ckpt); err != nil {
+			return err
+		}
+		return ckpt.clear()
+	}
+}
+
+type batchResult struct {
+	entry    *batchEntry
+	status   string
+	err      error
+	attempts int
+	timedOut bool
+	start    time.Time
+	duration time.Duration
+	stdout   bytes.Buffer
+	stderr   bytes.Buffer
+}
+
+// Statuses of batch entries as written to the report.
+const (
+	batchOK        = "ok"
+	batchDone      = "done" // succeeded in an earlier run
+	batchFailed    = "failed"
+	batchCancelled = "cancelled"
+	batchSkipped   = "skipped"
+)
+
+// run executes the entries as they are read, keeping at most c.jobs of them running at a time.
+// Once an entry may take part in the flow of the batch, see [batchEntry.structured], the rest of the input
+// is read and linked first; then entries run in dependency and input order and those whose dependencies
+// did not succeed are skipped. A failure of an entry stops the whole batch, unless keepGoing is set,
+// the batch is a dependency graph or the failure is handled, see [batchEntry.stops].
+// It returns the failures of all entries and of reading the input joined together.
+func (c *batchConfig) run(ctx context.Context, cs CommandSelector, br batchReader, rep *batchReport, ckpt *batchCheckpoint) error {
+	jobs := max(c.jobs, 1)
+	stopOnError := !c.keepGoing
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// The output is buffered from the start, as -j 0 turns into GOMAXPROCS once the batch turns out to be a graph.
+	buffered := c.ordered && c.jobs != 1
+	results := make(chan *batchResult)
+	out := batchOutput{stdout: Stdout(ctx), stderr: Stderr(ctx), done: make(map[int]*batchResult)}
+
+	// The input is read concurrently, so that commands finishing meanwhile are noticed.
+	type batchRead struct {
+		entry *batchEntry
+		err   error
+	}
+	incoming := make(chan batchRead)
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		for {
+			e, err := br.next()
+			select {
+			case incoming <- batchRead{e, err}:
+			case <-quit:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var (
+		entries  []*batchEntry
+		pending  []int
+		ready    []int
+		started  []bool
+		handled  []bool // error handlers queued already, each runs at most once
+		finished []bool
+		status   []string
+		errs     []error
+	)
+	push := func(i int) {
+		if j, found := slices.BinarySearch(ready, i); !found {
+			ready = slices.Insert(ready, j, i)
+		}
+	}
+	var settle func(res *batchResult)
+	settle = func(res *batchResult) {
+		e := res.entry
+		finished[e.index], status[e.index] = true, res.status
+		if buffered {
+			out.add(res)
+		}
+		rep.add(res)
+		if res.status == batchOK && len(e.Args) > 0 && e.main() {
+			if err := ckpt.record(e); err != nil {
+				log.Warn("could not record checkpoint", "entry", e.name(), "err", err)
+			}
+		}
+		for _, d := range e.dependents {
+			if finished[d.index] {
+				continue
+			}
+			if res.status != batchOK && res.status != batchDone {
+				settle(&batchResult{entry: d, status: batchSkipped, err: fmt.Errorf("needs %s which %s", e.name(), res.status)})
+				continue
+			}
+			if pending[d.index]--; pending[d.index] == 0 {
+				push(d.index)
+			}
+		}
+		for _, f := range e.followers {
+			if pending[f.index]--; pending[f.index] == 0 && f.main() && !finished[f.index] {
+				push(f.index)
+			}
+		}
+	}
+
+	// Whether a failure stops the batch or runs an error handler depends on the entries after it,
+	// so the decision waits while they are not read or not linked yet.
+	stopped := false
+	var undecided []*batchResult
+	decide := func() {
+		for _, res := range undecided {
+			if h := res.entry.handler; h != nil && !handled[h.index] {
+				handled[h.index] = true
+				push(h.index)
+			}
+			if !res.entry.stops(stopOnError) {
+				log.Warn("command failed", "entry", res.entry.name(), "err", res.err)
+				continue
+			}
+			if !stopped {
+				stopped = true
+				cancel(res.err)
+			}
+		}
+		undecided = nil
+	}
+
+	// link turns the batch into a graph once the whole input is read, taking into account
+	// the entries which already ran.
+	linked := false
+	link := func() error {
+		graph, err := linkBatch(entries)
+		if err != nil {
+			return err
+		}
+		linked = true
+		if graph {
+			stopOnError = false
+			if c.jobs == 0 {
+				jobs = runtime.GOMAXPROCS(0)
+			}
+		}
+		ready = nil
+		waiting := func(e *batchEntry) bool { return !started[e.index] && !finished[e.index] }
+		for _, e := range entries {
+			if !waiting(e) {
+				continue
+			}
+			for _, w := range e.waitsFor() {
+				if !finished[w.index] {
+					pending[e.index]++
+				}
+			}
+		}
+		for _, e := range entries {
+			if !waiting(e) {
+				continue
+			}
+			if e.main() && ckpt.completed(e) {
+				settle(&batchResult{entry: e, status: batchDone})
+				continue
+			}
+			for _, dep := range e.deps {
+				if finished[dep.index] && status[dep.index] != batchOK && status[dep.index] != batchDone {
+					settle(&batchResult{entry: e, status: batchSkipped, err: fmt.Errorf("needs %s which %s", dep.name(), status[dep.index])})
+					break
+				}
+			}
+			if !finished[e.index] && pending[e.index] == 0 && e.main() {
+				push(e.index)
+			}
+		}
+		return nil
+	}
+
+	inputDone, collecting := false, false
+	read := func(r batchRead) {
+		if r.err != nil {
+			inputDone = true
+			if !errors.Is(r.err, io.EOF) {
+				errs = append(errs, r.err)
+			} else if collecting {
+				if err := link(); err != nil {
+					errs = append(errs, err)
+				} else {
+					collecting = false
+				}
+			}
+			decide()
+			return
+		}
+		e := r.entry
+		entries = append(entries, e)
+		pending = append(pending, 0)
+		started = append(started, false)
+		handled = append(handled, false)
+		finished = append(finished, false)
+		status = append(status, "")
+		if collecting = collecting || e.structured(); collecting {
+			return
+		}
+		decide() // e has no condition, so it does not handle earlier failures
+		if ckpt.completed(e) {
+			settle(&batchResult{entry: e, status: batchDone})
+		} else {
+			push(e.index)
+		}
+	}
+
+	running, interrupted := 0, false
+	for {
+		for !collecting && running < jobs && len(ready) > 0 && ctx.Err() == nil {
+			e := entries[ready[0]]
+			ready = ready[1:]
+			if finished[e.index] {
+				continue
+			}
+			if e.previous != nil && !e.runs(status[e.previous.index]) {
+				settle(&batchResult{entry: e, status: batchSkipped})
+				continue
+			}
+			started[e.index] = true
+			go func() {
+				results <- c.exec(ctx, cs, e, buffered)
+			}()
+			running++
+		}
+		in := incoming
+		if inputDone || !collecting && len(ready) > 0 && ctx.Err() == nil {
+			in = nil // no need to read ahead while entries wait for their turn
+		}
+		if running == 0 && in == nil {
+			break
+		}
+		select {
+		case r := <-in:
+			read(r)
+		case res := <-results:
+			running--
+			switch {
+			case res.status != batchFailed || ctx.Err() == nil:
+			case !stopped:
+				// the caller cancelled the batch, its cause is reported instead of the failure
+				res.status, interrupted = batchCancelled, true
+			case errors.Is(res.err, context.Canceled):
+				res.status = batchCancelled
+			}
+			settle(res)
+			if res.status != batchFailed {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("batch entry %s %q: %w", res.entry.name(), res.entry.Args, res.err))
+			undecided = append(undecided, res)
+			if !collecting && (inputDone || res.entry.index < len(entries)-1) {
+				decide()
+			}
+		}
+	}
+	notRun := interrupted
+	for _, e := range entries {
+		if !finished[e.index] && !e.Finally {
+			notRun = notRun || !e.isHandler // handlers which were not needed are skipped quietly
+			settle(&batchResult{entry: e, status: batchSkipped})
+		}
+	}
+	for _, e := range entries {
+		if !e.Finally {
+			continue
+		}
+		if !linked || e.previous != nil && !e.runs(status[e.previous.index]) {
+			settle(&batchResult{entry: e, status: batchSkipped})
+			continue
+		}
+		res := c.finally(ctx, cs, e, buffered)
+		settle(res)
+		if res.status == batchFailed {
+			errs = append(errs, fmt.Errorf("batch finally step %s %q: %w", e.name(), e.Args, res.err))
+		}
+	}
+	out.flush(true)
+	if notRun && !stopped {
+		errs = append(errs, context.Cause(ctx))
+	}
+	errs = append(errs, rep.finish())
+	return errors.Join(errs...)
+}
+
+// finally runs a finally step. It runs even when the batch was cancelled,
+// so it gets a fresh context limited to its own timeout or to batchFinallyTimeout.
+func (c *batchConfig) finally(ctx context.Context, cs CommandSelector, e *batchEntry, buffered bool) *batchResult {
+	timeout := time.Duration(e.Timeout)
+	if timeout <= 0 {
+		timeout = batchFinallyTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+	return c.exec(ctx, cs, e, buffered)
+}
+
+// exec runs a single entry with its timeout and retry policy, capturing its output when buffered.
+func (c *batchConfig) exec(ctx context.Context, cs CommandSelector, e *batchEntry, buffered bool) *batchResult {
+	res := &batchResult{entry: e, start: time.Now(), status: batchOK}
+	if len(e.Args) == 0 {
+		return res
+	}
+	if buffered {
+		ctx = WithStderr(WithStdout(ctx, &res.stdout), &res.stderr)
+	}
+	ctx, closeOutput, err := redirect(ctx, e)
+	if err != nil {
+		res.err, res.status = err, batchFailed
+		return res
+	}
+	c.execAttempts(ctx, selectCommand(cs, e.Args[0], e.Args[1:]), res)
+	res.err = errors.Join(res.err, closeOutput())
+	res.duration = time.Since(res.start)
+	if res.err != nil {
+		res.status = batchFailed
+	}
+	return res
+}
+
+// redirect returns the context with the outputs named by the "stdout" and "stderr" fields of the entry
+// and a function closing them. The names follow [DynamicWriter], except that "-" (or "stdout") and "=" (or "stderr")
+// refer to the outputs of the entry, so "stderr": "-" sends the standard error where the standard output goes, like 2>&1.
+func redirect(ctx context.Context, e *batchEntry) (context.Context, func() error, error) {
+	stdout, stderr := Stdout(ctx), Stderr(ctx)
+	var files []io.Closer
+	closeAll := func() error {
+		var errs []error
+		for _, f := range files {
+			errs = append(errs, f.Close())
+		}
+		return errors.Join(errs...)
+	}
+	for _, r := range []struct {
+		name string
+		w    *io.Writer
+	}{
+		{e.Stdout, &stdout},
+		{e.Stderr, &stderr},
+	} {
+		switch r.name {
+		case "", "-", "stdout", "=", "stderr":
+			continue
+		}
+		f, err := DynamicWriter(r.name, e.Append)
+		if err != nil {
+			return ctx, nil, errors.Join(err, closeAll())
+		}
+		files = append(files, f)
+		*r.w = f
+	}
+	switch e.Stdout {
+	case "=", "stderr":
+		stdout = stderr
+	}
+	switch e.Stderr {
+	case "-", "stdout":
+		stderr = stdout
+	}
+	return WithStderr(WithStdout(ctx, stdout), stderr), closeAll, nil
+}
+
+// batchOutput writes buffered results in input order.
+type batchOutput struct {
+	stdout io.Writer
+	stderr io.Writer
+	next   int
+	done   map[int]*batchResult
+}
+
+func (o *batchOutput) add(res *batchResult) {
+	o.done[res.entry.index] = res
+	o.flush(false)
+}
+
+// flush writes the results completed in sequence; with all set, it also writes those after gaps.
+func (o *batchOutput) flush(all bool) {
+	for len(o.done) > 0 {
+		res, ok := o.done[o.next]
+		o.next++
+		if !ok {
+			if all {
+				continue
+			}
+			o.next--
+			return
+		}
+		delete(o.done, res.entry.index)
+		_, _ = res.stdout.WriteTo(o.stdout)
+		_, _ = res.stderr.WriteTo(o.stderr)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/teghnet/x/internal"
+)
+
+// batchCheckpointDir is the directory under the StatePath holding batch checkpoints.
+const batchCheckpointDir = "batch"
+
+// stateDir returns the checkpoint directory of the configured XDG or of the App ctx was derived from.
+func (c *batchConfig) stateDir(ctx context.Context) (string, error) {
+	xdg, ok := appXDG(ctx, c.state)
+	if !ok {
+		return "", errors.New("batch: checkpoints need an app context or the BatchState option")
+	}
+	return xdg.StatePath(batchCheckpointDir), nil
+}
+
+// batchCheckpointName derives the checkpoint file name from the batch input.
+func batchCheckpointName(input string) string {
+	if input == "" || input == "-" || input == "stdin" {
+		return "stdin.jsonl"
+	}
+	if abs, err := filepath.Abs(input); err == nil {
+		input = abs
+	}
+	base := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	return fmt.Sprintf("%s-%s.jsonl", base, hex.EncodeToString(internal.Hash(input)[:4]))
+}
+
+// batchCheckpointRecord is a line of the checkpoint file.
+type batchCheckpointRecord struct {
+	Key   string    `json:"key"`
+	Index int       `json:"index"`
+	Args  []string  `json:"args"`
+	At    time.Time `json:"at"`
+}
+
+// batchCheckpoint records the entries which succeeded so a rerun of the batch can skip them.
+// A nil *batchCheckpoint records nothing.
+type batchCheckpoint struct {
+	path string
+	done map[string]bool
+	f    *os.File
+	enc  *json.Encoder
+}
+
+// openBatchCheckpoint loads the checkpoint at path, creating it if needed.
+func openBatchCheckpoint(path string) (*batchCheckpoint, error) {
+	c := &batchCheckpoint{path: path}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	done, size, err := readBatchCheckpoint(f)
+	if err == nil {
+		// A record without its newline was cut short by a crash. Its entry runs again,
+		// and the record is dropped so that the next ones start on a line of their own.
+		err = f.Truncate(size)
+	}
+	if err != nil {
+		ClosePrint(f)
+		return nil, fmt.Errorf("checkpoint %s: %w", path, err)
+	}
+	c.done = done
+	c.f, c.enc = f, json.NewEncoder(f)
+	return c, nil
+}
+
+// readBatchCheckpoint returns the keys of the entries recorded in a checkpoint
+// and the size of its complete records, ignoring a partial last one.
+func readBatchCheckpoint(r io.Reader) (map[string]bool, int64, error) {
+	done := make(map[string]bool)
+	br := bufio.NewReader(r)
+	var size int64
+	for {
+		line, err := br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return done, size, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		var rec batchCheckpointRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, 0, err
+		}
+		done[rec.Key] = true
+		size += int64(len(line))
+	}
+}
+
+// checkpointKey identifies an entry by its position and arguments.
+func checkpointKey(e *batchEntry) string {
+	return hex.EncodeToString(internal.Hash(append([]string{strconv.Itoa(e.index)}, e.Args...)...))
+}
+
+// completed reports whether the entry succeeded in an earlier run.
+func (c *batchCheckpoint) completed(e *batchEntry) bool {
+	return c != nil && c.done[checkpointKey(e)]
+}
+
+// record stores the entry as succeeded.
+func (c *batchCheckpoint) record(e *batchEntry) error {
+	if c == nil {
+		return nil
+	}
+	key := checkpointKey(e)
+	c.done[key] = true
+	return c.enc.Encode(batchCheckpointRecord{Key: key, Index: e.index, Args: e.Args, At: time.Now()})
+}
+
+// Close implements [io.Closer].
+func (c *batchCheckpoint) Close() error {
+	if c == nil || c.f == nil {
+		return nil
+	}
+	return c.f.Close()
+}
+
+// clear closes and removes the checkpoint; it is called once the whole batch succeeded.
+func (c *batchCheckpoint) clear() error {
+	if c == nil {
+		return nil
+	}
+	f := c.f
+	c.f = nil
+	return errors.Join(f.Close(), os.Remove(c.path))
+}
+
+// listBatchCheckpoints writes the checkpoints in dir with the number of completed entries.
+func listBatchCheckpoints(w io.Writer, dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range files {
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		done, _, err := readBatchCheckpoint(f)
+		ClosePrint(f)
+		if err != nil {
+			return fmt.Errorf("checkpoint %s: %w", name, err)
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%d completed\t%s\n", name, len(done), info.ModTime().Format(time.DateTime))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Batch input formats.
+const (
+	BatchFormatJSON  = "json"
+	BatchFormatShell = "shell"
+)
+
+// batchFormat returns the format of the named input, guessing it from the extension when not given.
+func batchFormat(format, name string) (string, error) {
+	switch format {
+	case BatchFormatJSON, BatchFormatShell:
+		return format, nil
+	case "":
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".sh", ".txt":
+			return BatchFormatShell, nil
+		}
+		return BatchFormatJSON, nil
+	}
+	return "", fmt.Errorf("batch: unknown format %q", format)
+}
+
+// batchReader reads the entries of a batch input one at a time and expands variables in their arguments.
+// next returns io.EOF after the last entry. The entries are numbered in input order.
+type batchReader interface {
+	next() (*batchEntry, error)
+}
+
+// newBatchReader returns a reader of the batch input in the given format.
+func newBatchReader(r io.Reader, format string, vars *batchVars) batchReader {
+	if format == BatchFormatShell {
+		return &shellBatchReader{sc: bufio.NewScanner(r), vars: vars}
+	}
+	return &jsonBatchReader{dec: json.NewDecoder(r), vars: vars}
+}
+
+// shellBatchReader reads one shell-style command per line, see [ShellSplit].
+// Lines ending inside quotes or with a backslash continue on the next line.
+// Like in a shell, placeholders in single quotes or after a backslash are not expanded.
+type shellBatchReader struct {
+	sc     *bufio.Scanner
+	vars   *batchVars
+	lineNo int
+	n      int
+}
+
+func (r *shellBatchReader) next() (*batchEntry, error) {
+	var pending string
+	startNo := 0
+	for r.sc.Scan() {
+		r.lineNo++
+		if pending == "" {
+			startNo = r.lineNo
+			pending = r.sc.Text()
+		} else {
+			pending += "\n" + r.sc.Text()
+		}
+		args, err := ShellSplit(escapeQuotedVars(pending))
+		if errors.Is(err, ErrUnterminated) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("batch line %d: %w", startNo, err)
+		}
+		pending = ""
+		if args, err = r.vars.expandAll(args); err != nil {
+			return nil, fmt.Errorf("batch line %d: %w", startNo, err)
+		}
+		if len(args) > 0 {
+			e := &batchEntry{index: r.n, Args: args}
+			r.n++
+			return e, nil
+		}
+	}
+	if err := r.sc.Err(); err != nil {
+		return nil, err
+	}
+	if pending != "" {
+		return nil, fmt.Errorf("batch line %d: %w", startNo, ErrUnterminated)
+	}
+	return nil, io.EOF
+}
+
+// jsonBatchReader decodes a stream of batch entries, see [batchEntry.UnmarshalJSON].
+// Entries with only a "set" object are directives defining variables for the entries that follow.
+type jsonBatchReader struct {
+	dec       *json.Decoder
+	vars      *batchVars
+	inFinally bool
+	n         int
+}
+
+func (r *jsonBatchReader) next() (*batchEntry, error) {
+	for r.dec.More() {
+		e := &batchEntry{index: r.n}
+		if err := r.dec.Decode(e); err != nil {
+			return nil, fmt.Errorf("batch entry %d: %w", r.n, err)
+		}
+		if e.Finally && len(e.Args) == 0 && e.Set == nil {
+			r.inFinally = true // {"finally":true} starts the finally section
+			continue
+		}
+		e.Finally = e.Finally || r.inFinally
+		if err := e.checkSteps(); err != nil {
+			return nil, fmt.Errorf("batch entry %d: %w", r.n, err)
+		}
+		if e.Set != nil {
+			if len(e.Args) > 0 {
+				return nil, fmt.Errorf("batch entry %d: set directive with args", r.n)
+			}
+			if err := r.vars.define(e.Set); err != nil {
+				return nil, fmt.Errorf("batch entry %d: %w", r.n, err)
+			}
+			continue
+		}
+		var err error
+		if e.Args, err = r.vars.expandAll(e.Args); err != nil {
+			return nil, fmt.Errorf("batch entry %d: %w", r.n, err)
+		}
+		for _, name := range []*string{&e.Stdout, &e.Stderr} {
+			if *name, err = r.vars.expand(*name); err != nil {
+				return nil, fmt.Errorf("batch entry %d: %w", r.n, err)
+			}
+		}
+		r.n++
+		return e, nil
+	}
+	return nil, io.EOF
+}
+
+// batchEntry is a single command of a batch.
+type batchEntry struct {
+	ID      string       `json:"id"`
+	Args    []string     `json:"args"`
+	Needs   []string     `json:"needs"`
+	Timeout jsonDuration `json:"timeout"`
+	Retry   *RetryPolicy `json:"retry"`
+
+	// Stdout and Stderr redirect the output of the command, see redirect.
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+	Append bool   `json:"append"`
+
+	// OnError, If and Finally control the flow of the batch around the entry, see [Batch].
+	OnError string `json:"on_error"`
+	If      string `json:"if"`
+	Finally bool   `json:"finally"`
+
+	// Set makes the entry a directive defining variables, see [batchVars].
+	Set map[string]string `json:"set"`
+
+	index      int
+	deps       []*batchEntry
+	dependents []*batchEntry
+	previous   *batchEntry   // the entry whose result the If condition is about
+	followers  []*batchEntry // entries with an If condition about this one
+	handler    *batchEntry   // run when this entry fails
+	isHandler  bool          // run only when another entry fails
+}
+
+// UnmarshalJSON accepts either a plain array of arguments
+// or an object: {"id":"fetch","args":[...],"needs":["init"]}.
+func (e *batchEntry) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		return json.Unmarshal(data, &e.Args)
+	}
+	type entry batchEntry
+	return json.Unmarshal(data, (*entry)(e))
+}
+
+// name identifies the entry in messages.
+func (e *batchEntry) name() string {
+	if e.ID != "" {
+		return e.ID
+	}
+	return fmt.Sprintf("#%d", e.index)
+}
+
+// linkBatch resolves the needs of the entries and rejects unknown references and cycles.
+// It reports whether any entry depends on another.
+func linkBatch(entries []*batchEntry) (bool, error) {
+	byID := make(map[string]*batchEntry)
+	for _, e := range entries {
+		if e.ID == "" {
+			continue
+		}
+		if _, ok := byID[e.ID]; ok {
+			return false, fmt.Errorf("batch: duplicate id %q", e.ID)
+		}
+		byID[e.ID] = e
+	}
+	graph := false
+	for _, e := range entries {
+		for _, id := range e.Needs {
+			dep, ok := byID[id]
+			if !ok {
+				return false, fmt.Errorf("batch: %s needs unknown id %q", e.name(), id)
+			}
+			e.deps = append(e.deps, dep)
+			dep.dependents = append(dep.dependents, e)
+			graph = true
+		}
+	}
+	if err := linkSteps(entries, byID); err != nil {
+		return false, err
+	}
+	return graph, findCycle(entries)
+}
+
+// findCycle returns an error naming the entries of the first dependency cycle found,
+// including the implicit dependencies of If conditions.
+func findCycle(entries []*batchEntry) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[*batchEntry]int, len(entries))
+	var stack []*batchEntry
+	var visit func(e *batchEntry) error
+	visit = func(e *batchEntry) error {
+		switch state[e] {
+		case visiting:
+			i := slices.Index(stack, e)
+			names := make([]string, 0, len(stack)-i+1)
+			for _, s := range append(stack[i:], e) {
+				names = append(names, s.name())
+			}
+			return fmt.Errorf("batch: dependency cycle: %s", strings.Join(names, " -> "))
+		case visited:
+			return nil
+		}
+		state[e] = visiting
+		stack = append(stack, e)
+		for _, dep := range e.waitsFor() {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[e] = visited
+		return nil
+	}
+	for _, e := range entries {
+		if err := visit(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// batchRecord is the report line written for each batch entry.
+type batchRecord struct {
+	Type     string    `json:"type"`
+	Index    int       `json:"index"`
+	ID       string    `json:"id,omitempty"`
+	Args     []string  `json:"args"`
+	Start    time.Time `json:"start,omitzero"`
+	Duration string    `json:"duration,omitempty"`
+	Status   string    `json:"status"`
+	Attempts int       `json:"attempts,omitempty"`
+	TimedOut bool      `json:"timed_out,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// batchSummary is the last line of the report.
+type batchSummary struct {
+	Type     string         `json:"type"`
+	Total    int            `json:"total"`
+	Statuses map[string]int `json:"statuses"`
+	Duration string         `json:"duration"`
+}
+
+// batchReport writes batch results as JSONL. A nil *batchReport discards everything.
+type batchReport struct {
+	w        io.WriteCloser
+	enc      *json.Encoder
+	start    time.Time
+	total    int
+	statuses map[string]int
+	err      error
+}
+
+// newBatchReport opens the report named by [DynamicWriter] rules; an empty name disables the report.
+func newBatchReport(name string) (*batchReport, error) {
+	if name == "" {
+		return nil, nil
+	}
+	w, err := DynamicWriter(name, false)
+	if err != nil {
+		return nil, err
+	}
+	return &batchReport{w: w, enc: json.NewEncoder(w), start: time.Now(), statuses: make(map[string]int)}, nil
+}
+
+func (r *batchReport) add(res *batchResult) {
+	if r == nil {
+		return
+	}
+	r.total++
+	r.statuses[res.status]++
+	rec := batchRecord{
+		Type:     "command",
+		Index:    res.entry.index,
+		ID:       res.entry.ID,
+		Args:     res.entry.Args,
+		Start:    res.start,
+		Status:   res.status,
+		Attempts: res.attempts,
+		TimedOut: res.timedOut,
+	}
+	if !res.start.IsZero() {
+		rec.Duration = res.duration.String()
+	}
+	if res.err != nil {
+		rec.Error = res.err.Error()
+	}
+	r.write(rec)
+}
+
+// finish writes the summary and returns the first error encountered while writing the report.
+func (r *batchReport) finish() error {
+	if r == nil {
+		return nil
+	}
+	r.write(batchSummary{Type: "summary", Total: r.total, Statuses: r.statuses, Duration: time.Since(r.start).String()})
+	return r.err
+}
+
+func (r *batchReport) write(v any) {
+	if r.err == nil {
+		r.err = r.enc.Encode(v)
+	}
+}
+
+// Close implements [io.Closer]. Standard output and error are left open.
+func (r *batchReport) Close() error {
+	if r == nil || r.w == os.Stdout || r.w == os.Stderr {
+		return nil
+	}
+	return r.w.Close()
+}
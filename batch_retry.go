@@ -0,0 +1,166 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"time"
+
+	"charm.land/log/v2"
+)
+
+// RetryPolicy describes how [Batch] retries failed commands.
+type RetryPolicy struct {
+	// Attempts is the total number of attempts; values below 2 disable retries.
+	Attempts int
+	// Backoff is the delay before the second attempt. It doubles with every further attempt.
+	Backoff time.Duration
+	// MaxBackoff caps the delay; zero means no cap.
+	MaxBackoff time.Duration
+	// Match restricts retries to errors whose message matches; nil retries every error.
+	Match *regexp.Regexp
+}
+
+// retryable reports whether a command that failed with err on the given attempt should run again.
+func (p RetryPolicy) retryable(attempt int, err error) bool {
+	return err != nil && attempt < p.Attempts && (p.Match == nil || p.Match.MatchString(err.Error()))
+}
+
+// delay returns the jittered exponential delay after the given attempt.
+// The result is between half and the whole of the exponential delay.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff
+	for range attempt - 1 {
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+		d *= 2
+	}
+	if p.MaxBackoff > 0 {
+		d = min(d, p.MaxBackoff)
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// merge returns p with the fields set in the entry policy overriding it.
+func (p RetryPolicy) merge(o *RetryPolicy) RetryPolicy {
+	if o == nil {
+		return p
+	}
+	if o.Attempts != 0 {
+		p.Attempts = o.Attempts
+	}
+	if o.Backoff != 0 {
+		p.Backoff = o.Backoff
+	}
+	if o.MaxBackoff != 0 {
+		p.MaxBackoff = o.MaxBackoff
+	}
+	if o.Match != nil {
+		p.Match = o.Match
+	}
+	return p
+}
+
+// UnmarshalJSON reads the policy of a batch entry:
+// {"attempts":3,"backoff":"1s","max_backoff":"30s","match":"timeout|503"}.
+func (p *RetryPolicy) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Attempts   int          `json:"attempts"`
+		Backoff    jsonDuration `json:"backoff"`
+		MaxBackoff jsonDuration `json:"max_backoff"`
+		Match      string       `json:"match"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*p = RetryPolicy{Attempts: v.Attempts, Backoff: time.Duration(v.Backoff), MaxBackoff: time.Duration(v.MaxBackoff)}
+	if v.Match != "" {
+		re, err := regexp.Compile(v.Match)
+		if err != nil {
+			return fmt.Errorf("retry match: %w", err)
+		}
+		p.Match = re
+	}
+	return nil
+}
+
+// jsonDuration is a time.Duration written in JSON as a string like "1m30s".
+type jsonDuration time.Duration
+
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"1m30s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = jsonDuration(v)
+	return nil
+}
+
+// execAttempts runs the entry until it succeeds, its retry policy gives up or ctx is done.
+// Every attempt gets its own timeout.
+func (c *batchConfig) execAttempts(ctx context.Context, cmd Command, res *batchResult) {
+	e := res.entry
+	policy := c.retry.merge(e.Retry)
+	timeout := c.timeout
+	if e.Timeout != 0 {
+		timeout = time.Duration(e.Timeout)
+	}
+	for {
+		res.attempts++
+		res.err = attempt(ctx, cmd, timeout)
+		if errors.Is(res.err, context.DeadlineExceeded) && ctx.Err() == nil {
+			res.timedOut = true
+			log.Warn("command timed out", "entry", e.name(), "attempt", res.attempts, "timeout", timeout)
+		}
+		if ctx.Err() != nil || !policy.retryable(res.attempts, res.err) {
+			return
+		}
+		d := policy.delay(res.attempts)
+		log.Warn("retrying command", "entry", e.name(), "attempt", res.attempts, "delay", d, "err", res.err)
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// attempt runs cmd once, cancelling it after the timeout if one is set.
+func attempt(ctx context.Context, cmd Command, timeout time.Duration) error {
+	if timeout <= 0 {
+		return cmd(ctx)
+	}
+	cause := fmt.Errorf("timed out after %s: %w", timeout, context.DeadlineExceeded)
+	actx, cancel := context.WithTimeoutCause(ctx, timeout, cause)
+	defer cancel()
+	err := cmd(actx)
+	if err == nil || ctx.Err() != nil || !errors.Is(context.Cause(actx), cause) {
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return cause
+	}
+	return fmt.Errorf("%w: %w", cause, err)
+}
+
+// compileOptional compiles the expression unless it is empty.
+func compileOptional(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Values of the "on_error" and "if" fields of batch entries.
+const (
+	batchOnErrorContinue = "continue"
+	batchOnErrorStop     = "stop"
+	batchOnErrorRun      = "run:"
+
+	batchIfFailed    = "previous_failed"
+	batchIfSucceeded = "previous_succeeded"
+)
+
+// batchFinallyTimeout limits the finally steps without a timeout of their own.
+// They run after the batch was stopped or cancelled, so they must not hang.
+const batchFinallyTimeout = 30 * time.Second
+
+// checkSteps checks the values of the "on_error" and "if" fields of the entry.
+func (e *batchEntry) checkSteps() error {
+	switch {
+	case e.OnError == "", e.OnError == batchOnErrorContinue, e.OnError == batchOnErrorStop:
+	case strings.HasPrefix(e.OnError, batchOnErrorRun):
+	default:
+		return fmt.Errorf("on_error must be %q, %q or %q followed by an id, got %q",
+			batchOnErrorContinue, batchOnErrorStop, batchOnErrorRun, e.OnError)
+	}
+	switch e.If {
+	case "", batchIfFailed, batchIfSucceeded:
+	default:
+		return fmt.Errorf("if must be %q or %q, got %q", batchIfFailed, batchIfSucceeded, e.If)
+	}
+	return nil
+}
+
+// linkSteps checks how the "on_error", "if" and "finally" fields of the entries fit together,
+// see [batchEntry.checkSteps], and links the error handlers and the entries with conditions to the entries they refer to.
+func linkSteps(entries []*batchEntry, byID map[string]*batchEntry) error {
+	for _, e := range entries {
+		id, ok := strings.CutPrefix(e.OnError, batchOnErrorRun)
+		if !ok {
+			continue
+		}
+		h, ok := byID[id]
+		if !ok {
+			return fmt.Errorf("batch: %s: on_error runs unknown id %q", e.name(), id)
+		}
+		if h == e {
+			return fmt.Errorf("batch: %s: on_error runs the entry itself", e.name())
+		}
+		e.handler, h.isHandler = h, true
+	}
+	for _, e := range entries {
+		if (e.isHandler || e.Finally) && (len(e.deps) > 0 || len(e.dependents) > 0) {
+			return fmt.Errorf("batch: %s: error handlers and finally steps cannot take part in needs", e.name())
+		}
+		if e.If == "" {
+			continue
+		}
+		if e.isHandler {
+			return fmt.Errorf("batch: %s: an error handler cannot have a condition", e.name())
+		}
+		for i := e.index - 1; i >= 0 && e.previous == nil; i-- {
+			if p := entries[i]; !p.isHandler && (e.Finally || !p.Finally) {
+				e.previous = p
+			}
+		}
+		if e.previous == nil {
+			return fmt.Errorf("batch: %s: if needs a previous entry", e.name())
+		}
+		e.previous.followers = append(e.previous.followers, e)
+	}
+	return nil
+}
+
+// waitsFor returns the entries which must finish before this one starts.
+func (e *batchEntry) waitsFor() []*batchEntry {
+	if e.previous == nil {
+		return e.deps
+	}
+	return append(e.deps[:len(e.deps):len(e.deps)], e.previous)
+}
+
+// structured reports whether the entry may take part in the flow of the batch: it has an id other entries
+// may refer to, it refers to other entries or it is a finally step. The batch must be read to the end
+// and linked before such an entry can run, see [linkBatch]; an entry with an id may turn out to be
+// an error handler, which runs only when needed.
+func (e *batchEntry) structured() bool {
+	return e.ID != "" || len(e.Needs) > 0 || e.If != "" || e.Finally || strings.HasPrefix(e.OnError, batchOnErrorRun)
+}
+
+// main reports whether the entry runs in the main part of the batch,
+// that is, it is neither an error handler nor a finally step.
+func (e *batchEntry) main() bool {
+	return !e.isHandler && !e.Finally
+}
+
+// runs reports whether the condition of the entry holds, given the status of the previous entry.
+func (e *batchEntry) runs(previous string) bool {
+	switch e.If {
+	case batchIfFailed:
+		return previous == batchFailed
+	case batchIfSucceeded:
+		return previous == batchOK || previous == batchDone
+	}
+	return true
+}
+
+// stops reports whether the failure of the entry stops the batch, given the default.
+// A failure handled by an error handler or by a following "if": "previous_failed" entry does not.
+func (e *batchEntry) stops(def bool) bool {
+	switch {
+	case e.OnError == batchOnErrorStop:
+		return true
+	case e.OnError != "":
+		return false
+	}
+	for _, f := range e.followers {
+		if f.If == batchIfFailed && !f.Finally {
+			return false
+		}
+	}
+	return def
+}
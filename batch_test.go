@@ -0,0 +1,660 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/teghnet/x"
+)
+
+// writeBatch writes the batch input to a temporary file and returns its name.
+func writeBatch(t *testing.T, name, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestBatch_Parallel(t *testing.T) {
+	var mu sync.Mutex
+	var running, peak int
+	cs := func(name string, args []string) x.Command {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			running++
+			peak = max(peak, running)
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				running--
+				mu.Unlock()
+			}()
+			d, _ := strconv.Atoi(args[0])
+			time.Sleep(time.Duration(d) * time.Millisecond)
+			_, err := fmt.Fprintln(x.Stdout(ctx), name)
+			return err
+		}
+	}
+	in := writeBatch(t, "b.jsonl", `["a","30"] ["b","10"] ["c","20"] ["d","0"]`)
+
+	var out bytes.Buffer
+	ctx := x.WithStdout(t.Context(), &out)
+	if err := x.Batch([]string{"-i", in, "-j", "4", "-ordered"}, cs)(ctx); err != nil {
+		t.Fatalf("Batch() error = %v", err)
+	}
+	if got, want := out.String(), "a\nb\nc\nd\n"; got != want {
+		t.Errorf("Batch() output = %q, want %q", got, want)
+	}
+	if peak < 2 {
+		t.Errorf("Batch() peak concurrency = %d, want > 1", peak)
+	}
+}
+
+func TestBatch_StopOnError(t *testing.T) {
+	errBoom := errors.New("boom")
+	var ran atomic.Int32
+	cs := func(name string, args []string) x.Command {
+		return func(ctx context.Context) error {
+			ran.Add(1)
+			if name == "fail" {
+				return errBoom
+			}
+			return nil
+		}
+	}
+	in := writeBatch(t, "b.jsonl", `["ok"] ["fail"] ["ok"] ["ok"]`)
+
+	err := x.Batch([]string{"-i", in}, cs)(t.Context())
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("Batch() error = %v, want %v", err, errBoom)
+	}
+	if ran.Load() != 2 {
+		t.Errorf("Batch() ran %d commands, want 2", ran.Load())
+	}
+
+	ran.Store(0)
+	if err := x.Batch([]string{"-i", in, "-continue"}, cs)(t.Context()); !errors.Is(err, errBoom) {
+		t.Fatalf("Batch(-continue) error = %v, want %v", err, errBoom)
+	}
+	if ran.Load() != 4 {
+		t.Errorf("Batch(-continue) ran %d commands, want 4", ran.Load())
+	}
+}
+
+func TestBatch_Report(t *testing.T) {
+	errBoom := errors.New("boom")
+	cs := func(name string, args []string) x.Command {
+		return func(ctx context.Context) error {
+			if name == "fail" {
+				return errBoom
+			}
+			return nil
+		}
+	}
+	in := writeBatch(t, "b.jsonl", `["ok"] ["fail"] ["ok"] ["fail"]`)
+	report := filepath.Join(t.TempDir(), "report.jsonl")
+
+	err := x.Batch([]string{"-i", in, "-continue", "-report", report}, cs)(t.Context())
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("Batch() error = %v, want %v", err, errBoom)
+	}
+	if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != 2 {
+		t.Errorf("Batch() joined %d errors, want 2", n)
+	}
+
+	f, err := os.Open(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var records []map[string]any
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var rec map[string]any
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+	if len(records) != 5 {
+		t.Fatalf("report has %d records, want 5", len(records))
+	}
+	for i, want := range []string{"ok", "failed", "ok", "failed"} {
+		if records[i]["status"] != want || records[i]["index"] != float64(i) {
+			t.Errorf("record %d = %v, want status %q", i, records[i], want)
+		}
+	}
+	summary := records[4]
+	if summary["type"] != "summary" || summary["total"] != float64(4) {
+		t.Errorf("summary = %v", summary)
+	}
+}
+
+func TestBatch_Graph(t *testing.T) {
+	var mu sync.Mutex
+	var ran []string
+	cs := func(name string, args []string) x.Command {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			ran = append(ran, args[0])
+			mu.Unlock()
+			if name == "fail" {
+				return errors.New("boom")
+			}
+			return nil
+		}
+	}
+	in := writeBatch(t, "b.jsonl", `
+{"id":"build","args":["ok","build"],"needs":["init"]}
+{"id":"init","args":["ok","init"]}
+{"id":"fetch","args":["fail","fetch"],"needs":["init"]}
+{"id":"unpack","args":["ok","unpack"],"needs":["fetch"]}
+{"id":"deploy","args":["ok","deploy"],"needs":["build","unpack"]}
+["ok","lint"]
+`)
+	report := filepath.Join(t.TempDir(), "report.jsonl")
+	err := x.Batch([]string{"-i", in, "-j", "1", "-report", report}, cs)(t.Context())
+	if err == nil {
+		t.Fatal("Batch() error = nil, want failure of fetch")
+	}
+	if want := []string{"init", "build", "fetch", "lint"}; !slices.Equal(ran, want) {
+		t.Errorf("Batch() ran %q, want %q", ran, want)
+	}
+	data, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"unpack", "deploy"} {
+		if !bytes.Contains(data, []byte(`"id":"`+id+`","args":["ok","`+id+`"],"status":"skipped"`)) {
+			t.Errorf("report does not mark %s as skipped:\n%s", id, data)
+		}
+	}
+}
+
+func TestBatch_GraphCycle(t *testing.T) {
+	var ran atomic.Int32
+	cs := func(string, []string) x.Command {
+		return func(context.Context) error {
+			ran.Add(1)
+			return nil
+		}
+	}
+	in := writeBatch(t, "b.jsonl", `
+{"id":"a","args":["a"],"needs":["c"]}
+{"id":"b","args":["b"],"needs":["a"]}
+{"id":"c","args":["c"],"needs":["b"]}
+`)
+	err := x.Batch([]string{"-i", in}, cs)(t.Context())
+	if err == nil || !strings.Contains(err.Error(), "a -> c -> b -> a") {
+		t.Errorf("Batch() error = %v, want dependency cycle", err)
+	}
+	if ran.Load() != 0 {
+		t.Errorf("Batch() ran %d commands, want 0", ran.Load())
+	}
+
+	// a plain entry before the graph is streamed, the graph is still checked before any of its entries runs
+	for in, want := range map[string]string{
+		`["first"] {"id":"a","args":["a"],"needs":["b"]} {"id":"b","args":["b"],"needs":["a"]}`: "a -> b -> a",
+		`["first"] {"id":"a","args":["a"],"needs":["nope"]}`:                                    `unknown id "nope"`,
+		`["first"] {"id":"a","args":["a"]} {"id":"a","args":["a"]}`:                             `duplicate id "a"`,
+	} {
+		ran.Store(0)
+		err := x.Batch([]string{"-i", writeBatch(t, "b.jsonl", in)}, cs)(t.Context())
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Batch(%s) error = %v, want %q", in, err, want)
+		}
+		if ran.Load() > 1 {
+			t.Errorf("Batch(%s) ran %d commands, want at most the first one", in, ran.Load())
+		}
+	}
+}
+
+func TestBatch_TimeoutAndRetry(t *testing.T) {
+	var calls atomic.Int32
+	cs := func(name string, args []string) x.Command {
+		return func(ctx context.Context) error {
+			switch name {
+			case "flaky":
+				if calls.Add(1) < 3 {
+					return errors.New("503 unavailable")
+				}
+				return nil
+			case "hang":
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return errors.New("permanent")
+		}
+	}
+	in := writeBatch(t, "b.jsonl", `
+{"args":["flaky"],"retry":{"attempts":5,"backoff":"1ms","match":"^503"}}
+{"args":["hang"],"timeout":"10ms"}
+{"args":["broken"],"retry":{"attempts":5,"match":"^503"}}
+`)
+	report := filepath.Join(t.TempDir(), "report.jsonl")
+	err := x.Batch([]string{"-i", in, "-continue", "-report", report}, cs)(t.Context())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Batch() error = %v, want deadline exceeded", err)
+	}
+	data, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"status":"ok","attempts":3`,
+		`"status":"failed","attempts":1,"timed_out":true`,
+		`"status":"failed","attempts":1,"error":"permanent"`,
+	} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("report does not contain %s:\n%s", want, data)
+		}
+	}
+}
+
+// testXDG keeps all app directories in a single temporary directory.
+type testXDG string
+
+func (d testXDG) CachePath(s ...string) string {
+	return filepath.Join(append([]string{string(d), "cache"}, s...)...)
+}
+func (d testXDG) ConfigPath(s ...string) string {
+	return filepath.Join(append([]string{string(d), "config"}, s...)...)
+}
+func (d testXDG) DataPath(s ...string) string {
+	return filepath.Join(append([]string{string(d), "data"}, s...)...)
+}
+func (d testXDG) StatePath(s ...string) string {
+	return filepath.Join(append([]string{string(d), "state"}, s...)...)
+}
+
+func TestBatch_Resume(t *testing.T) {
+	var ran []string
+	failing := true
+	cs := func(name string, args []string) x.Command {
+		return func(context.Context) error {
+			ran = append(ran, name)
+			if name == "flaky" && failing {
+				return errors.New("boom")
+			}
+			return nil
+		}
+	}
+	in := writeBatch(t, "b.jsonl", `["a"] ["flaky"] ["b"]`)
+	xdg := testXDG(t.TempDir())
+	state := x.BatchState(xdg)
+
+	if err := x.Batch([]string{"-i", in, "-resume", "-continue"}, cs, state)(t.Context()); err == nil {
+		t.Fatal("Batch() first run error = nil, want failure")
+	}
+	// a crash while writing a record leaves it cut short
+	ckpts, err := filepath.Glob(filepath.Join(string(xdg), "state", "*", "*.jsonl"))
+	if err != nil || len(ckpts) != 1 {
+		t.Fatalf("checkpoints = %q, %v", ckpts, err)
+	}
+	f, err := os.OpenFile(ckpts[0], os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"key":"4f`)
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var list bytes.Buffer
+	if err := x.Batch([]string{"-checkpoints"}, cs, state)(x.WithStdout(t.Context(), &list)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(list.String(), "2 completed") {
+		t.Errorf("checkpoints = %q, want 2 completed entries", list.String())
+	}
+
+	ran, failing = nil, false
+	if err := x.Batch([]string{"-i", in, "-resume"}, cs, state)(t.Context()); err != nil {
+		t.Fatalf("Batch() second run error = %v", err)
+	}
+	if want := []string{"flaky"}; !slices.Equal(ran, want) {
+		t.Errorf("Batch() second run ran %q, want %q", ran, want)
+	}
+
+	ran = nil
+	if err := x.Batch([]string{"-i", in, "-resume"}, cs, state)(t.Context()); err != nil {
+		t.Fatalf("Batch() third run error = %v", err)
+	}
+	if len(ran) != 3 {
+		t.Errorf("Batch() after success ran %q, want all entries", ran)
+	}
+}
+
+func TestBatch_Variables(t *testing.T) {
+	var got [][]string
+	cs := func(name string, args []string) x.Command {
+		return func(context.Context) error {
+			got = append(got, append([]string{name}, args...))
+			return nil
+		}
+	}
+	t.Setenv("BATCH_TEST_HOME", "/home/test")
+	in := writeBatch(t, "b.jsonl", `
+{"set":{"DIR":"${BATCH_TEST_HOME}/data","DATE":"1999-01-01"}}
+["copy","${DIR}/${DATE}.json","$${DIR}","a$b"]
+{"set":{"DIR":"/tmp"}}
+["copy","${DIR}/${DATE}.json"]
+`)
+	err := x.Batch([]string{"-i", in, "-var", "DATE=2026-10-17"}, cs)(t.Context())
+	if err != nil {
+		t.Fatalf("Batch() error = %v", err)
+	}
+	want := [][]string{
+		{"copy", "/home/test/data/2026-10-17.json", "${DIR}", "a$b"},
+		{"copy", "/tmp/2026-10-17.json"},
+	}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("Batch() ran %q, want %q", got, want)
+	}
+
+	for range 10 { // the keys of a set directive come in random order
+		got = nil
+		in = writeBatch(t, "chain.jsonl", `{"set":{"A":"x","B":"${A}y","C":"${B}z","D":"${C}w","PATH":"${PATH}:/x"}} ["echo","${D}","${PATH}"]`)
+		if err := x.Batch([]string{"-i", in, "-var", "PATH=/bin"}, cs)(t.Context()); err != nil {
+			t.Fatalf("Batch(chain) error = %v", err)
+		}
+		if want := [][]string{{"echo", "xyzw", "/bin"}}; !slices.EqualFunc(got, want, slices.Equal) {
+			t.Fatalf("Batch(chain) ran %q, want %q", got, want)
+		}
+	}
+	in = writeBatch(t, "cycle.jsonl", `{"set":{"A":"${B}","B":"${A}"}} ["echo","${A}"]`)
+	if err := x.Batch([]string{"-i", in}, cs)(t.Context()); err == nil || !strings.Contains(err.Error(), "refer to each other") {
+		t.Errorf("Batch(cycle) error = %v, want variables referring to each other", err)
+	}
+
+	got = nil
+	in = writeBatch(t, "b.sh", `copy '${DIR}' "${DIR}" \${DIR} ${DIR}/'$$' # ${DIR} '`)
+	if err := x.Batch([]string{"-i", in, "-var", "DIR=/d"}, cs)(t.Context()); err != nil {
+		t.Fatalf("Batch(shell) error = %v", err)
+	}
+	if want := [][]string{{"copy", "${DIR}", "/d", "${DIR}", "/d/$$"}}; !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("Batch(shell) ran %q, want %q", got, want)
+	}
+
+	in = writeBatch(t, "strict.jsonl", `["copy","${BATCH_TEST_UNDEFINED}"]`)
+	err = x.Batch([]string{"-i", in, "-strict"}, cs)(t.Context())
+	if !errors.Is(err, x.ErrUndefinedVariable) {
+		t.Errorf("Batch(-strict) error = %v, want %v", err, x.ErrUndefinedVariable)
+	}
+}
+
+func TestBatch_Redirect(t *testing.T) {
+	cs := func(name string, args []string) x.Command {
+		return func(ctx context.Context) error {
+			_, _ = fmt.Fprintln(x.Stdout(ctx), name, "out")
+			_, err := fmt.Fprintln(x.Stderr(ctx), name, "err")
+			return err
+		}
+	}
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "a.log")
+	if err := os.WriteFile(logFile, []byte("before\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	in := writeBatch(t, "b.jsonl", `
+		{"set": {"DIR": "`+dir+`"}}
+		{"args": ["a"], "stdout": "${DIR}/a.log", "stderr": "-", "append": true}
+		{"args": ["b"], "stdout": "${DIR}/b.out", "stderr": "${DIR}/b.err"}
+		["c"]
+	`)
+	var stdout, stderr bytes.Buffer
+	ctx := x.WithStderr(x.WithStdout(t.Context(), &stdout), &stderr)
+	if err := x.Batch([]string{"-i", in}, cs)(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"a.log": "before\na out\na err\n",
+		"b.out": "b out\n",
+		"b.err": "b err\n",
+	} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("%s = %q, want %q", name, b, want)
+		}
+	}
+	if got, want := stdout.String(), "c out\n"; got != want {
+		t.Errorf("stdout = %q, want %q", got, want)
+	}
+	if got, want := stderr.String(), "c err\n"; got != want {
+		t.Errorf("stderr = %q, want %q", got, want)
+	}
+}
+
+func TestBatch_Steps(t *testing.T) {
+	errBoom := errors.New("boom")
+	var mu sync.Mutex
+	var ran []string
+	cs := func(name string, args []string) x.Command {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			ran = append(ran, name)
+			mu.Unlock()
+			if strings.HasPrefix(name, "fail") {
+				return errBoom
+			}
+			return nil
+		}
+	}
+	in := writeBatch(t, "b.jsonl", `
+		{"id": "build", "args": ["fail"], "on_error": "run:notify"}
+		{"args": ["fix"], "if": "previous_failed"}
+		{"args": ["deploy"], "if": "previous_succeeded"}
+		{"id": "notify", "args": ["notify"]}
+		{"id": "lint", "args": ["fail-lint"]}
+		{"args": ["skipped"], "if": "previous_succeeded"}
+		["never"]
+		{"finally": true}
+		["cleanup"]
+		{"args": ["after-failure"], "if": "previous_failed"}
+	`)
+	err := x.Batch([]string{"-i", in}, cs)(t.Context())
+	if !errors.Is(err, errBoom) || !strings.Contains(err.Error(), "build") || !strings.Contains(err.Error(), "lint") {
+		t.Errorf("Batch() error = %v, want failures of build and lint", err)
+	}
+	if want := []string{"fail", "fix", "deploy", "notify", "fail-lint", "cleanup"}; !slices.Equal(ran, want) {
+		t.Errorf("Batch() ran %q, want %q", ran, want)
+	}
+
+	ran = nil
+	in = writeBatch(t, "b.jsonl", `
+		{"args": ["fail"], "on_error": "stop"}
+		["never"]
+		{"args": ["cleanup"], "finally": true}
+	`)
+	if err := x.Batch([]string{"-i", in, "-continue"}, cs)(t.Context()); !errors.Is(err, errBoom) {
+		t.Errorf("Batch(-continue) error = %v, want %v", err, errBoom)
+	}
+	if want := []string{"fail", "cleanup"}; !slices.Equal(ran, want) {
+		t.Errorf("Batch(-continue) ran %q, want %q", ran, want)
+	}
+
+	for _, in := range []string{
+		`{"args": ["a"], "on_error": "run:nope"}`,
+		`{"args": ["a"], "on_error": "retry"}`,
+		`{"args": ["a"], "if": "previous_failed"}`,
+		`["a"] {"args": ["b"], "if": "always"}`,
+		`{"id": "a", "args": ["a"], "needs": ["b"]} {"id": "b", "args": ["b"], "if": "previous_failed"}`,
+		`{"args": ["a"], "on_error": "run:h"} {"id": "h", "args": ["h"], "needs": ["a"]}`,
+	} {
+		if err := x.Batch([]string{"-i", writeBatch(t, "b.jsonl", in)}, cs)(t.Context()); err == nil {
+			t.Errorf("Batch(%s): want error", in)
+		}
+	}
+}
+
+func TestBatch_HandlerRunsOnce(t *testing.T) {
+	var handled atomic.Int32
+	started := make(chan struct{})
+	var once sync.Once
+	cs := func(name string, args []string) x.Command {
+		return func(ctx context.Context) error {
+			switch name {
+			case "handle":
+				handled.Add(1)
+				once.Do(func() { close(started) })
+				time.Sleep(50 * time.Millisecond)
+				return nil
+			case "fail-later":
+				<-started
+			}
+			return errors.New("boom")
+		}
+	}
+	in := writeBatch(t, "b.jsonl", `
+		{"args": ["fail"], "on_error": "run:h"}
+		{"args": ["fail-later"], "on_error": "run:h"}
+		{"id": "h", "args": ["handle"]}
+	`)
+	if err := x.Batch([]string{"-i", in, "-j", "4"}, cs)(t.Context()); err == nil {
+		t.Error("Batch(): want error")
+	}
+	if n := handled.Load(); n != 1 {
+		t.Errorf("the error handler ran %d times, want 1", n)
+	}
+}
+
+func TestBatch_HandlerBeforeEntry(t *testing.T) {
+	var mu sync.Mutex
+	var ran []string
+	cs := func(name string, args []string) x.Command {
+		return func(context.Context) error {
+			mu.Lock()
+			ran = append(ran, name)
+			mu.Unlock()
+			if name == "fail" {
+				return errors.New("boom")
+			}
+			return nil
+		}
+	}
+	in := writeBatch(t, "b.jsonl", `{"id": "h", "args": ["handler"]} ["ok1"] {"args": ["fail"], "on_error": "run:h"}`)
+	if err := x.Batch([]string{"-i", in}, cs)(t.Context()); err == nil {
+		t.Error("Batch(): want error")
+	}
+	if want := []string{"ok1", "fail", "handler"}; !slices.Equal(ran, want) {
+		t.Errorf("Batch() ran %q, want %q", ran, want)
+	}
+}
+
+func TestBatch_CancelCause(t *testing.T) {
+	errStop := errors.New("user stop")
+	ctx, cancel := context.WithCancelCause(t.Context())
+	cs := func(name string, args []string) x.Command {
+		return func(ctx context.Context) error {
+			cancel(errStop)
+			<-ctx.Done()
+			return ctx.Err()
+		}
+	}
+	in := writeBatch(t, "b.jsonl", `["wait"]`)
+	report := filepath.Join(t.TempDir(), "report.jsonl")
+	err := x.Batch([]string{"-i", in, "-report", report}, cs)(ctx)
+	if !errors.Is(err, errStop) {
+		t.Errorf("Batch() error = %v, want %v", err, errStop)
+	}
+	data, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"status":"cancelled"`)) {
+		t.Errorf("report does not mark the entry as cancelled:\n%s", data)
+	}
+}
+
+func TestBatch_FinallyAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cleaned := make(chan error, 1)
+	cs := func(name string, args []string) x.Command {
+		return func(ctx context.Context) error {
+			if name == "cleanup" {
+				if _, ok := ctx.Deadline(); !ok {
+					cleaned <- errors.New("no deadline")
+				}
+				cleaned <- ctx.Err()
+				return nil
+			}
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		}
+	}
+	in := writeBatch(t, "b.jsonl", `["wait"] {"finally": true} ["cleanup"]`)
+	if err := x.Batch([]string{"-i", in}, cs)(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Batch() error = %v, want context.Canceled", err)
+	}
+	select {
+	case err := <-cleaned:
+		if err != nil {
+			t.Errorf("finally step: %v", err)
+		}
+	default:
+		t.Error("the finally step did not run")
+	}
+}
+
+func TestBatch_Streaming(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() { os.Stdin = stdin })
+
+	first := make(chan struct{})
+	var ran []string
+	var mu sync.Mutex
+	cs := func(name string, args []string) x.Command {
+		return func(context.Context) error {
+			mu.Lock()
+			ran = append(ran, name)
+			mu.Unlock()
+			if name == "first" {
+				close(first)
+			}
+			return nil
+		}
+	}
+	go func() {
+		defer w.Close()
+		_, _ = w.WriteString(`["first"]` + "\n")
+		select {
+		case <-first:
+		case <-time.After(5 * time.Second):
+			return // the batch waited for the end of the input
+		}
+		_, _ = w.WriteString(`["second"]` + "\n" + `{"args": ["third"`)
+	}()
+	err = x.Batch([]string{"-i", "-", "-j", "2"}, cs)(t.Context())
+	if err == nil || !strings.Contains(err.Error(), "batch entry 2") {
+		t.Errorf("Batch() error = %v, want an error about entry 2", err)
+	}
+	if want := []string{"first", "second"}; !slices.Equal(ran, want) {
+		t.Errorf("Batch() ran %q, want %q", ran, want)
+	}
+}
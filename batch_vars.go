@@ -0,0 +1,244 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"charm.land/log/v2"
+)
+
+// batchVars expands ${NAME} placeholders in batch arguments.
+// Values given with -var win over those set by {"set":{...}} directives, which win over the environment.
+type batchVars struct {
+	flags  map[string]string
+	set    map[string]string
+	strict bool
+}
+
+// ErrUndefinedVariable is returned in strict mode for placeholders without a value.
+var ErrUndefinedVariable = errors.New("undefined variable")
+
+func (v *batchVars) lookup(name string) (string, bool) {
+	if val, ok := v.flags[name]; ok {
+		return val, true
+	}
+	if val, ok := v.set[name]; ok {
+		return val, true
+	}
+	return os.LookupEnv(name)
+}
+
+// define records the values of a set directive; the values may refer to variables defined earlier
+// and to each other. They are expanded in dependency order, so the order of the keys does not matter;
+// a value referring to its own name gets the earlier value of the variable.
+func (v *batchVars) define(set map[string]string) error {
+	if v.set == nil {
+		v.set = make(map[string]string)
+	}
+	const (
+		resolving = iota + 1
+		resolved
+	)
+	state := make(map[string]int, len(set))
+	var resolve func(name string, path []string) error
+	resolve = func(name string, path []string) error {
+		switch state[name] {
+		case resolving:
+			return fmt.Errorf("variables refer to each other: %s", strings.Join(append(path, name), " -> "))
+		case resolved:
+			return nil
+		}
+		state[name] = resolving
+		for _, ref := range varRefs(set[name]) {
+			if _, ok := set[ref]; ok && ref != name {
+				if err := resolve(ref, append(path, name)); err != nil {
+					return err
+				}
+			}
+		}
+		expanded, err := v.expand(set[name])
+		if err != nil {
+			return err
+		}
+		v.set[name], state[name] = expanded, resolved
+		return nil
+	}
+	names := slices.Sorted(maps.Keys(set))
+	for _, name := range names {
+		if !validVarName(name) {
+			return fmt.Errorf("invalid variable name %q", name)
+		}
+	}
+	for _, name := range names {
+		if err := resolve(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// varRefs returns the names of the variables referred to in s, see [batchVars.expand].
+func varRefs(s string) []string {
+	var names []string
+	for i := 0; i+1 < len(s); i++ {
+		if s[i] != '$' {
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			i++
+		case '{':
+			if end := strings.IndexByte(s[i+2:], '}'); end >= 0 {
+				names = append(names, s[i+2:i+2+end])
+				i += 2 + end
+			}
+		}
+	}
+	return names
+}
+
+// escapeQuotedVars doubles every $ of a shell-style line which a shell would keep literally:
+// in single quotes and after a backslash, so that [batchVars.expand] does not fill placeholders there.
+// The line is to be split with [ShellSplit] afterwards.
+func escapeQuotedVars(line string) string {
+	if !strings.Contains(line, "$") {
+		return line
+	}
+	var b strings.Builder
+	inArg, quote := false, byte(0)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		b.WriteByte(c)
+		switch {
+		case quote == '\'':
+			if c == '$' {
+				b.WriteByte('$')
+			}
+			if c == '\'' {
+				quote = 0
+			}
+		case c == '\\' && i+1 < len(line):
+			i++
+			b.WriteByte(line[i])
+			if line[i] == '$' {
+				b.WriteByte('$')
+			}
+			inArg = inArg || quote == 0 && line[i] != '\n'
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote, inArg = c, true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			inArg = false
+		case c == '#' && !inArg:
+			// a comment runs to the end of the line
+			end := strings.IndexByte(line[i:], '\n')
+			if end < 0 {
+				end = len(line) - i
+			}
+			b.WriteString(line[i+1 : i+end])
+			i += end - 1
+		default:
+			inArg = true
+		}
+	}
+	return b.String()
+}
+
+// expandAll expands every argument.
+func (v *batchVars) expandAll(args []string) ([]string, error) {
+	out := make([]string, len(args))
+	for i, a := range args {
+		var err error
+		if out[i], err = v.expand(a); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// expand replaces ${NAME} with the value of the variable. "$$" stands for a literal "$".
+// Undefined variables expand to an empty string or, in strict mode, cause an error.
+func (v *batchVars) expand(s string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable in %q", s)
+			}
+			name := s[i+2 : i+2+end]
+			if !validVarName(name) {
+				return "", fmt.Errorf("invalid variable name %q in %q", name, s)
+			}
+			val, ok := v.lookup(name)
+			if !ok {
+				if v.strict {
+					return "", fmt.Errorf("%w: %s", ErrUndefinedVariable, name)
+				}
+				log.Warn("undefined variable", "name", name)
+			}
+			b.WriteString(val)
+			i += 2 + end
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+func validVarName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// varsFlag collects repeated -var key=value flags.
+type varsFlag map[string]string
+
+func (f varsFlag) String() string {
+	return fmt.Sprint(map[string]string(f))
+}
+
+func (f varsFlag) Set(s string) error {
+	name, val, ok := strings.Cut(s, "=")
+	if !ok || !validVarName(name) {
+		return fmt.Errorf("expected name=value, got %q", s)
+	}
+	f[name] = val
+	return nil
+}
+
+// clone returns a copy of the map, so flags do not modify the defaults given with [BatchVars].
+func (f varsFlag) clone() varsFlag {
+	if f == nil {
+		return make(varsFlag)
+	}
+	return maps.Clone(f)
+}
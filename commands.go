@@ -2,75 +2,56 @@ package x
 
 import (
 	"context"
-	"encoding/json"
-	"iter"
+	"errors"
 
-	"charm.land/log/v2"
+	"github.com/teghnet/x/app"
+	"github.com/teghnet/x/paths"
 )
 
 type Command func(context.Context) error
 type CommandSelector func(string, []string) Command
 
 // BatchOnce returns the Batch if the first argument is "batch",
-// otherwise it runs the CommandSelector.
+// the Shell if it is "shell", the Watch if it is "watch", otherwise it runs the CommandSelector.
 //
 // This is to prevent an infinite batch command loop.
 func BatchOnce(args []string, cs CommandSelector) Command {
-	if args[0] == "batch" {
+	if len(args) == 0 {
+		return failed(&UsageError{Err: errors.New("no command given")})
+	}
+	switch args[0] {
+	case "batch":
 		return Batch(args[1:], cs)
+	case "shell":
+		return Shell(args[1:], cs)
+	case "watch":
+		return Watch(args[1:], cs)
 	}
-	return cs(args[0], args[2:])
+	return selectCommand(cs, args[0], args[1:])
+}
+
+// builtinFlags describes the flags of the commands handled by [BatchOnce] itself, for shell completion.
+var builtinFlags = map[string]func() []FlagOption{
+	"batch": BatchFlags,
+	"shell": ShellFlags,
+	"watch": WatchFlags,
 }
 
-func Batch(args []string, cs CommandSelector) Command {
-	return func(ctx context.Context) error {
-		inputFile := "-"
-		keepRunningWhenError := false
-		err := FlagsParse(args,
-			Flag(&inputFile, "i", "batch input file"),
-			Flag(&keepRunningWhenError, "continue", "keep running even if there are errors"),
-		)
-		if err != nil {
-			return err
-		}
-		f, err := DynamicReader(inputFile)
-		if err != nil {
-			return err
-		}
-		defer ClosePrint(f)
-		for err := range batchExec(ctx, json.NewDecoder(f), cs) {
-			if err != nil {
-				if !keepRunningWhenError {
-					return err
-				}
-				log.Warn("command failed", "err", err)
-			}
-		}
-		return nil
+// selectCommand runs the CommandSelector and guards against it returning nil.
+func selectCommand(cs CommandSelector, name string, args []string) Command {
+	if cmd := cs(name, args); cmd != nil {
+		return cmd
 	}
+	return failed(&UnknownCommandError{Name: name})
 }
 
-func batchExec(ctx context.Context, dec *json.Decoder, cs CommandSelector) iter.Seq[error] {
-	return func(yield func(error) bool) {
-		for dec.More() {
-			if err := ctx.Err(); err != nil {
-				_ = yield(context.Cause(ctx))
-				return
-			}
-			var args []string
-			if err := dec.Decode(&args); err != nil {
-				if !yield(err) {
-					return
-				}
-			}
-			if len(args) == 0 {
-				if !yield(nil) {
-					return
-				}
-			}
-			if !yield(cs(args[0], args[1:])(ctx)) {
-				return
-			}
-		}
+// appXDG returns xdg if set, otherwise the directories of the [app.App] ctx was derived from.
+func appXDG(ctx context.Context, xdg paths.XDG) (paths.XDG, bool) {
+	if xdg != nil {
+		return xdg, true
+	}
+	if a, ok := app.FromContext(ctx); ok {
+		return a, true
 	}
+	return nil, false
 }
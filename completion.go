@@ -0,0 +1,225 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// completeCommand is the hidden argument of the completion command used by the generated scripts.
+const completeCommand = "__complete"
+
+// completeFiles is the last line of the candidates when the shell should also offer file names.
+const completeFiles = ":file"
+
+// Completion returns the handler of a "completion" command for the registry:
+//
+//	reg.Handle("completion", x.Completion(reg), x.CommandShort("print a shell completion script"))
+//
+// `completion bash|zsh|fish` prints a script to be sourced by the shell. The script calls the program back
+// (`completion __complete <words>`) to list subcommands, flag names, values of flags set with [FlagEnum]
+// and paths for flags set with [FlagFile]. Flags of commands are known from [CommandFlags];
+// the built-in batch, shell and watch commands of [BatchOnce] are completed too.
+func Completion(reg *Registry) Handler {
+	return func(args []string) Command {
+		return func(ctx context.Context) error {
+			if len(args) == 0 {
+				return fmt.Errorf("%s completion: shell required, one of: bash, zsh, fish", reg.path)
+			}
+			if args[0] == completeCommand {
+				return reg.complete(Stdout(ctx), args[1:])
+			}
+			script, ok := completionScripts[args[0]]
+			if !ok {
+				return fmt.Errorf("%s completion: unsupported shell %q, use one of: bash, zsh, fish", reg.path, args[0])
+			}
+			prog := reg.path
+			_, err := fmt.Fprint(Stdout(ctx), strings.NewReplacer(
+				"{{prog}}", prog,
+				"{{func}}", "__"+regexp.MustCompile(`\W`).ReplaceAllString(prog, "_")+"_complete",
+				"{{complete}}", completeCommand,
+				"{{files}}", completeFiles,
+			).Replace(script))
+			return err
+		}
+	}
+}
+
+// complete writes the candidates for the last of the words typed after the program name.
+func (r *Registry) complete(w io.Writer, words []string) error {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur, typed := words[len(words)-1], words[:len(words)-1]
+	reg := r
+	var fs *flag.FlagSet
+	var value *flag.Flag // flag waiting for its value
+	for _, word := range typed {
+		switch {
+		case fs == nil:
+			e := reg.Lookup(word)
+			if builtin, ok := builtinFlags[word]; ok && reg == r && e == nil {
+				fs = flagSet(builtin()...)
+				continue
+			}
+			if e == nil {
+				return nil
+			}
+			if e.sub != nil {
+				reg = e.sub
+				continue
+			}
+			if fs = e.FlagSet(); fs == nil {
+				fs = flag.NewFlagSet(e.Name, flag.ContinueOnError)
+			}
+		case value != nil:
+			value = nil
+		case word == "--":
+			return writeCandidates(w, nil, true)
+		case strings.HasPrefix(word, "-") && !strings.Contains(word, "="):
+			if f := fs.Lookup(strings.TrimLeft(word, "-")); f != nil && !isBoolFlag(f) {
+				value = f
+			}
+		}
+	}
+	switch {
+	case fs == nil:
+		var names []string
+		for _, e := range reg.entries {
+			names = append(names, e.Name)
+		}
+		if reg == r && r.Lookup("help") == nil {
+			names = append(names, "help")
+		}
+		if reg == r {
+			for name := range builtinFlags {
+				if r.Lookup(name) == nil {
+					names = append(names, name)
+				}
+			}
+		}
+		return writeCandidates(w, withPrefix(names, "", cur), false)
+	case value != nil:
+		enum, file := flagAnnotations(value)
+		return writeCandidates(w, withPrefix(enum, "", cur), file)
+	case strings.HasPrefix(cur, "-") && strings.Contains(cur, "="):
+		name, val, _ := strings.Cut(cur, "=")
+		f := fs.Lookup(strings.TrimLeft(name, "-"))
+		if f == nil {
+			return nil
+		}
+		enum, _ := flagAnnotations(f)
+		return writeCandidates(w, withPrefix(enum, name+"=", val), false)
+	case strings.HasPrefix(cur, "-"):
+		var names []string
+		gnu := lookupMeta(fs) != nil && lookupMeta(fs).gnu
+		fs.VisitAll(func(f *flag.Flag) {
+			if gnu {
+				names = append(names, gnuName(f.Name))
+			} else {
+				names = append(names, "-"+f.Name)
+			}
+		})
+		return writeCandidates(w, withPrefix(names, "", cur), false)
+	}
+	return writeCandidates(w, nil, true)
+}
+
+// flagAnnotations returns the values and the file marker set with [FlagEnum] and [FlagFile].
+func flagAnnotations(f *flag.Flag) (enum []string, file bool) {
+	if v, ok := f.Value.(*annotatedValue); ok {
+		return v.enum, v.file
+	}
+	return nil, false
+}
+
+// withPrefix returns prefix+c for the candidates starting with cur.
+func withPrefix(candidates []string, prefix, cur string) []string {
+	var out []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, cur) {
+			out = append(out, prefix+c)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func writeCandidates(w io.Writer, candidates []string, files bool) error {
+	for _, c := range candidates {
+		if _, err := fmt.Fprintln(w, c); err != nil {
+			return err
+		}
+	}
+	if files {
+		_, err := fmt.Fprintln(w, completeFiles)
+		return err
+	}
+	return nil
+}
+
+var completionScripts = map[string]string{
+	"bash": `# bash completion for {{prog}}; add to ~/.bashrc:
+#   source <({{prog}} completion bash)
+{{func}}() {
+	local cur="${COMP_WORDS[COMP_CWORD]}" line files=0
+	COMPREPLY=()
+	while IFS= read -r line; do
+		if [[ $line == "{{files}}" ]]; then
+			files=1
+		else
+			COMPREPLY+=("$line")
+		fi
+	done < <("{{prog}}" completion {{complete}} "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null)
+	if ((files)); then
+		compopt -o filenames 2>/dev/null
+		mapfile -t -O "${#COMPREPLY[@]}" COMPREPLY < <(compgen -f -- "$cur")
+	fi
+}
+complete -F {{func}} {{prog}}
+`,
+	"zsh": `#compdef {{prog}}
+# zsh completion for {{prog}}; add to ~/.zshrc:
+#   source <({{prog}} completion zsh)
+{{func}}() {
+	local -a candidates
+	local line files=0
+	while IFS= read -r line; do
+		if [[ $line == "{{files}}" ]]; then
+			files=1
+		else
+			candidates+=("$line")
+		fi
+	done < <("{{prog}}" completion {{complete}} "${(@)words[2,CURRENT]}" 2>/dev/null)
+	(( ${#candidates} )) && compadd -- "${candidates[@]}"
+	(( files )) && _files
+}
+compdef {{func}} {{prog}}
+`,
+	"fish": `# fish completion for {{prog}}; add to ~/.config/fish/config.fish:
+#   {{prog}} completion fish | source
+function {{func}}
+	set -l words (commandline -opc) (commandline -ct)
+	set -e words[1]
+	set -l files 0
+	for line in ({{prog}} completion {{complete}} $words 2>/dev/null)
+		if test "$line" = "{{files}}"
+			set files 1
+		else
+			echo $line
+		end
+	end
+	if test $files = 1
+		__fish_complete_path (commandline -ct)
+	end
+end
+complete -c {{prog}} -f -a '({{func}})'
+`,
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/teghnet/x"
+)
+
+func TestCompletion(t *testing.T) {
+	noop := func([]string) x.Command { return func(context.Context) error { return nil } }
+	reg := x.NewRegistry("tool")
+	reg.Handle("convert", noop, x.CommandFlags(func() []x.FlagOption {
+		var in, format string
+		var verbose bool
+		return []x.FlagOption{
+			x.Flag(&in, "i", "input file"), x.FlagFile("i"),
+			x.Flag(&format, "format", "output format"), x.FlagEnum("format", "json", "xml"),
+			x.Flag(&verbose, "v", "verbose"),
+		}
+	}))
+	reg.Handle("export", noop, x.CommandFlags(func() []x.FlagOption {
+		var opts struct {
+			Output string `flag:"output,o" usage:"output file"`
+			Format string `flag:"format,f" usage:"output format"`
+		}
+		return []x.FlagOption{x.FlagsStruct(&opts), x.FlagFile("output"), x.FlagEnum("format", "json", "xml"), x.FlagsGNU()}
+	}))
+	reg.Handle("config", noop)
+	reg.Group("db").Handle("migrate", noop)
+	reg.Handle("completion", x.Completion(reg))
+
+	tests := []struct {
+		words []string
+		want  string
+	}{
+		{[]string{""}, "batch\ncompletion\nconfig\nconvert\ndb\nexport\nhelp\nshell\nwatch\n"},
+		{[]string{"b"}, "batch\n"},
+		{[]string{"batch", "-form"}, "-format\n"},
+		{[]string{"batch", "-format", ""}, "json\nshell\n"},
+		{[]string{"batch", "-i", ""}, ":file\n"},
+		{[]string{"watch", "-d"}, "-debounce\n-dir\n"},
+		{[]string{"db", "b"}, ""},
+		{[]string{"con"}, "config\nconvert\n"},
+		{[]string{"db", ""}, "migrate\n"},
+		{[]string{"convert", "-"}, "-format\n-i\n-v\n"},
+		{[]string{"convert", "-format", "x"}, "xml\n"},
+		{[]string{"convert", "-format=j"}, "-format=json\n"},
+		{[]string{"convert", "-i", ""}, ":file\n"},
+		{[]string{"convert", "-v", ""}, ":file\n"},
+		{[]string{"export", "-f", ""}, "json\nxml\n"},
+		{[]string{"export", "-o", ""}, ":file\n"},
+		{[]string{"nope", ""}, ""},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		args := append([]string{"completion", "__complete"}, tt.words...)
+		if err := x.BatchOnce(args, reg.Select)(x.WithStdout(t.Context(), &out)); err != nil {
+			t.Fatalf("complete %q error = %v", tt.words, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("complete %q = %q, want %q", tt.words, out.String(), tt.want)
+		}
+	}
+
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var out bytes.Buffer
+		if err := x.BatchOnce([]string{"completion", shell}, reg.Select)(x.WithStdout(t.Context(), &out)); err != nil {
+			t.Fatalf("completion %s error = %v", shell, err)
+		}
+		if !strings.Contains(out.String(), "tool completion __complete") && !strings.Contains(out.String(), `"tool" completion __complete`) {
+			t.Errorf("completion %s script does not call back the program:\n%s", shell, out.String())
+		}
+	}
+}
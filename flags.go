@@ -5,25 +5,40 @@ package x
 
 import (
 	"encoding"
+	"errors"
 	"flag"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 )
 
 // FlagsArgs parses command-line arguments and returns the remaining non-flag arguments after parsing.
-// It creates a new FlagSet with the provided options, parses the args, and returns unparsed arguments and any error.
+// It creates a new FlagSet with the provided options, parses the args, and returns the positional arguments,
+// including those bound with [Arg], and any error.
 func FlagsArgs(args []string, extras ...FlagOption) ([]string, error) {
 	extras = append(extras, FlagSetErrorHandling(flag.ContinueOnError))
 	fs := flagSet(extras...)
-	return fs.Args(), fs.Parse(args)
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	}
+	return fs.Args(), nil
 }
 
 // FlagsParse parses command-line arguments into a flag.FlagSet,
 // applying customizations via provided FlagOption functions.
+// Flags not given in the arguments may take their values from other sources, see [FlagsApp].
 func FlagsParse(args []string, extras ...FlagOption) error {
 	extras = append(extras, FlagSetErrorHandling(flag.ContinueOnError))
-	return flagSet(extras...).Parse(args)
+	return parseFlags(flagSet(extras...), args)
+}
+
+// usageError wraps parsing errors in a [*UsageError].
+func usageError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &UsageError{Err: err}
 }
 
 // flagSet creates a new flag.FlagSet.
@@ -92,6 +107,10 @@ func (f *stringSlice) String() string {
 	return fmt.Sprint([]string(*f))
 }
 
+func (f *stringSlice) Get() any {
+	return []string(*f)
+}
+
 func (f *stringSlice) Set(value string) error {
 	*f = append(*f, strings.Split(value, ",")...)
 	return nil
@@ -103,3 +122,102 @@ func FlagText(p encoding.TextUnmarshaler, name string, value encoding.TextMarsha
 		flags.TextVar(p, name, value, usage)
 	}
 }
+
+// FlagEnum restricts the flag defined earlier under the name to the given values.
+// Other values are reported after parsing, together with the failed checks, see [FlagRequired].
+// The values are also offered by shell completion, see [Completion].
+func FlagEnum(name string, values ...string) FlagOption {
+	return func(flags *flag.FlagSet) {
+		annotate(flags, name, func(v *annotatedValue) { v.enum = values })
+		addCheck(flags, []string{name}, func(flags *flag.FlagSet, _ map[string]bool) error {
+			var errs []error
+			for _, s := range flags.Lookup(name).Value.(*annotatedValue).invalid {
+				errs = append(errs, fmt.Errorf("flag -%s must be one of %s, got %q", name, strings.Join(values, ", "), s))
+			}
+			return errors.Join(errs...)
+		})
+	}
+}
+
+// FlagFile marks the flag defined earlier under the name as a file name (e.g. for [DynamicReader] or [DynamicWriter]),
+// so shell completion offers paths, see [Completion].
+func FlagFile(name string) FlagOption {
+	return func(flags *flag.FlagSet) {
+		annotate(flags, name, func(v *annotatedValue) { v.file = true })
+	}
+}
+
+// annotate wraps the value of the named flag in an annotatedValue and lets fn modify it.
+// The aliases of the flag, see [FlagsStruct], get the same annotatedValue.
+func annotate(flags *flag.FlagSet, name string, fn func(*annotatedValue)) {
+	f := flags.Lookup(name)
+	if f == nil {
+		panic(fmt.Sprintf("x: flag %q must be defined before it is annotated", name))
+	}
+	m := lookupMeta(flags)
+	if m != nil {
+		name = m.primary(name)
+		f = flags.Lookup(name)
+	}
+	v, ok := f.Value.(*annotatedValue)
+	if !ok {
+		v = &annotatedValue{Value: f.Value}
+		f.Value = v
+	}
+	fn(v)
+	if m == nil {
+		return
+	}
+	// aliases share the annotated value, so they are checked and completed alike
+	for alias, primary := range m.aliases {
+		if primary == name {
+			flags.Lookup(alias).Value = v
+		}
+	}
+}
+
+// annotatedValue carries the metadata of a flag used by validation and completion.
+type annotatedValue struct {
+	flag.Value
+	enum    []string
+	invalid []string // values outside of enum, reported by the check of FlagEnum
+	file    bool
+}
+
+// Set sets the underlying value. Values outside of the enum are only recorded, to be reported with the checks.
+func (v *annotatedValue) Set(s string) error {
+	if len(v.enum) > 0 && !slices.Contains(v.enum, s) {
+		v.invalid = append(v.invalid, s)
+		return nil
+	}
+	return v.Value.Set(s)
+}
+
+// String returns the underlying value. flag.PrintDefaults calls it on a zero annotatedValue too,
+// to tell whether the default is worth printing.
+func (v *annotatedValue) String() string {
+	if v.Value == nil {
+		return ""
+	}
+	return v.Value.String()
+}
+
+// IsBoolFlag keeps boolean flags usable without a value.
+func (v *annotatedValue) IsBoolFlag() bool {
+	b, ok := v.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// Get returns the underlying value, like the flag.Getter of the standard flags.
+func (v *annotatedValue) Get() any {
+	if g, ok := v.Value.(flag.Getter); ok {
+		return g.Get()
+	}
+	return v.Value.String()
+}
+
+// isBoolFlag reports whether the flag takes no value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
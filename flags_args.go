@@ -0,0 +1,157 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// flagArg is a positional argument declared with [Arg] or [ArgOptional].
+type flagArg struct {
+	name, usage string
+	set         func(string) error
+	optional    bool
+	variadic    bool
+}
+
+// Arg declares a required positional argument, bound like a flag with [Flag]. Arguments are taken
+// in the order of their declarations from what is left after the flags; a []string argument takes
+// all the remaining ones, at least one, and must be declared last. Missing and unexpected arguments
+// are reported as a [*UsageError], together with the failed checks of flags, see [FlagRequired].
+// The arguments are shown in the usage of the flags and in the help of registered commands.
+func Arg[T flaggables](p *T, name, usage string) FlagOption {
+	return declareArg(p, name, usage, false)
+}
+
+// ArgOptional declares a positional argument which may be left out, see [Arg].
+// It must not be followed by required arguments; a []string one takes any number of arguments.
+func ArgOptional[T flaggables](p *T, name, usage string) FlagOption {
+	return declareArg(p, name, usage, true)
+}
+
+func declareArg[T flaggables](p *T, name, usage string, optional bool) FlagOption {
+	return func(flags *flag.FlagSet) {
+		a := &flagArg{name: name, usage: usage, optional: optional}
+		if s, ok := any(p).(*[]string); ok {
+			a.variadic = true
+			a.set = func(v string) error {
+				*s = append(*s, v)
+				return nil
+			}
+		} else {
+			scratch := flag.NewFlagSet("", flag.ContinueOnError)
+			Flag(p, name, usage)(scratch)
+			a.set = scratch.Lookup(name).Value.Set
+		}
+		m := metaOf(flags)
+		if n := len(m.args); n > 0 {
+			switch last := m.args[n-1]; {
+			case last.variadic:
+				panic(fmt.Sprintf("x: argument %q follows the variadic argument %q", name, last.name))
+			case last.optional && !optional:
+				panic(fmt.Sprintf("x: required argument %q follows the optional argument %q", name, last.name))
+			}
+		}
+		m.args = append(m.args, a)
+		flags.Usage = func() { flagsUsage(flags) }
+	}
+}
+
+// bindArgs sets the declared arguments from the positional ones.
+// Without declared arguments, any positional arguments are accepted.
+func (m *flagMeta) bindArgs(args []string) error {
+	if len(m.args) == 0 {
+		return nil
+	}
+	var errs []error
+	for _, a := range m.args {
+		if len(args) == 0 {
+			if !a.optional {
+				errs = append(errs, fmt.Errorf("missing argument <%s>", a.name))
+			}
+			continue
+		}
+		values := args[:1]
+		if a.variadic {
+			values = args
+		}
+		args = args[len(values):]
+		for _, v := range values {
+			if err := a.set(v); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value %q for argument <%s>: %w", v, a.name, err))
+			}
+		}
+	}
+	if len(args) > 0 {
+		errs = append(errs, fmt.Errorf("unexpected arguments: %s", ShellJoin(args...)))
+	}
+	return errors.Join(errs...)
+}
+
+// argsSynopsis returns the declared arguments as shown after the command name,
+// e.g. "<src> [<dst>] <file>...", or "" if there are none.
+func argsSynopsis(flags *flag.FlagSet) string {
+	m := lookupMeta(flags)
+	if m == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(m.args))
+	for _, a := range m.args {
+		s := "<" + a.name + ">"
+		if a.variadic {
+			s += "..."
+		}
+		if a.optional {
+			s = "[" + s + "]"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+// printArgs prints the descriptions of the declared arguments, formatted like flag.PrintDefaults.
+func printArgs(w io.Writer, flags *flag.FlagSet) {
+	m := lookupMeta(flags)
+	if m == nil {
+		return
+	}
+	for _, a := range m.args {
+		_, _ = fmt.Fprintf(w, "  %s\n    \t%s\n", a.name, strings.ReplaceAll(a.usage, "\n", "\n    \t"))
+	}
+}
+
+// printFlags prints the descriptions of the flags, GNU-style if parsed so, see [FlagsGNU].
+func printFlags(flags *flag.FlagSet) {
+	if m := lookupMeta(flags); m != nil && m.gnu {
+		gnuDefaults(flags)
+		return
+	}
+	flags.PrintDefaults()
+}
+
+// flagsUsage is the usage of FlagSets with declared arguments or parsed GNU-style.
+func flagsUsage(flags *flag.FlagSet) {
+	w := flags.Output()
+	switch synopsis := argsSynopsis(flags); {
+	case synopsis != "":
+		usage := "Usage:"
+		if flags.Name() != "" {
+			usage += " " + flags.Name()
+		}
+		_, _ = fmt.Fprintln(w, usage, "[flags]", synopsis)
+	case flags.Name() == "":
+		_, _ = fmt.Fprintln(w, "Usage:")
+	default:
+		_, _ = fmt.Fprintf(w, "Usage of %s:\n", flags.Name())
+	}
+	printFlags(flags)
+	if argsSynopsis(flags) != "" {
+		_, _ = fmt.Fprintln(w, "Arguments:")
+		printArgs(w, flags)
+	}
+}
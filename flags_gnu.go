@@ -0,0 +1,179 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// FlagsGNU makes the flags parsed GNU-style instead of by the standard flag package.
+// Flags with single-character names are short flags, given as -v, which may be bundled (-abc)
+// and take values as -ofile or -o file. Longer names are long flags, given as --name, --name=value
+// or --name value. Boolean long flags are negated with --no-name. Flags may follow positional arguments;
+// everything after -- is positional. The same flags may be declared as always, e.g. with [FlagsStruct]
+// giving a long name and a short alias: `flag:"output,o"`.
+func FlagsGNU() FlagOption {
+	return func(flags *flag.FlagSet) {
+		metaOf(flags).gnu = true
+		flags.Usage = func() { flagsUsage(flags) }
+	}
+}
+
+// parseGNU parses the arguments GNU-style, see [FlagsGNU].
+// Like flag.FlagSet.Parse, it reports errors to the output of the FlagSet according to its error handling.
+func parseGNU(flags *flag.FlagSet, args []string) error {
+	err := gnuArgs(flags, args)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, flag.ErrHelp) {
+		_, _ = fmt.Fprintln(flags.Output(), err)
+	}
+	flags.Usage()
+	switch flags.ErrorHandling() {
+	case flag.ExitOnError:
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	case flag.PanicOnError:
+		panic(err)
+	}
+	return err
+}
+
+// gnuArgs sets the flags given in the arguments and leaves the positional arguments in flags.Args.
+func gnuArgs(flags *flag.FlagSet, args []string) error {
+	var positional []string
+	for len(args) > 0 {
+		arg := args[0]
+		args = args[1:]
+		var err error
+		switch {
+		case arg == "--":
+			positional = append(positional, args...)
+			args = nil
+		case strings.HasPrefix(arg, "--"):
+			args, err = gnuLong(flags, arg[2:], args)
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			args, err = gnuShorts(flags, arg[1:], args)
+		default:
+			positional = append(positional, arg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// Parse only records the positional arguments now, all flags are set.
+	return flags.Parse(append([]string{"--"}, positional...))
+}
+
+// gnuLong sets a long flag given as --name, --name=value, --name value or --no-name
+// and returns the arguments left.
+func gnuLong(flags *flag.FlagSet, arg string, args []string) ([]string, error) {
+	name, value, hasValue := strings.Cut(arg, "=")
+	f := flags.Lookup(name)
+	if f == nil || len(name) == 1 {
+		if neg, ok := strings.CutPrefix(name, "no-"); ok && !hasValue {
+			if f := flags.Lookup(neg); f != nil && len(neg) > 1 && isBoolFlag(f) {
+				return args, flags.Set(neg, "false")
+			}
+		}
+		if name == "help" {
+			return args, flag.ErrHelp
+		}
+		return args, fmt.Errorf("flag provided but not defined: --%s", name)
+	}
+	switch {
+	case hasValue:
+	case isBoolFlag(f):
+		value = "true"
+	case len(args) == 0:
+		return args, fmt.Errorf("flag needs an argument: --%s", name)
+	default:
+		value, args = args[0], args[1:]
+	}
+	if err := flags.Set(name, value); err != nil {
+		return args, fmt.Errorf("invalid value %q for flag --%s: %w", value, name, err)
+	}
+	return args, nil
+}
+
+// gnuShorts sets the short flags bundled in an argument like -abc, -ofile or -o file
+// and returns the arguments left.
+func gnuShorts(flags *flag.FlagSet, arg string, args []string) ([]string, error) {
+	for i, c := range arg {
+		name := string(c)
+		f := flags.Lookup(name)
+		if f == nil {
+			if name == "h" {
+				return args, flag.ErrHelp
+			}
+			return args, fmt.Errorf("flag provided but not defined: -%s", name)
+		}
+		if isBoolFlag(f) {
+			if err := flags.Set(name, "true"); err != nil {
+				return args, fmt.Errorf("invalid value for flag -%s: %w", name, err)
+			}
+			continue
+		}
+		value := strings.TrimPrefix(arg[i+len(name):], "=")
+		if value == "" && i+len(name) == len(arg) {
+			if len(args) == 0 {
+				return args, fmt.Errorf("flag needs an argument: -%s", name)
+			}
+			value, args = args[0], args[1:]
+		}
+		if err := flags.Set(name, value); err != nil {
+			return args, fmt.Errorf("invalid value %q for flag -%s: %w", value, name, err)
+		}
+		return args, nil
+	}
+	return args, nil
+}
+
+// gnuName returns the flag as given in GNU-style arguments: -n for short flags and --name for long ones.
+func gnuName(name string) string {
+	if len(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
+
+// gnuDefaults prints the descriptions of GNU-style flags, each long flag together with its short alias.
+func gnuDefaults(flags *flag.FlagSet) {
+	w := flags.Output()
+	m := metaOf(flags)
+	aliases := make(map[string][]string)
+	for alias, name := range m.aliases {
+		aliases[name] = append(aliases[name], alias)
+	}
+	flags.VisitAll(func(f *flag.Flag) {
+		if _, ok := m.aliases[f.Name]; ok {
+			return
+		}
+		names := append(aliases[f.Name], f.Name)
+		slices.SortFunc(names, func(a, b string) int {
+			return cmp.Or(cmp.Compare(min(len(a), 2), min(len(b), 2)), cmp.Compare(a, b)) // shorts first
+		})
+		for i, name := range names {
+			names[i] = gnuName(name)
+		}
+		line := "  " + strings.Join(names, ", ")
+		typ, usage := flag.UnquoteUsage(f)
+		if typ != "" {
+			line += " " + typ
+		}
+		if f.DefValue != "" && f.DefValue != "0" && f.DefValue != "false" && f.DefValue != "[]" {
+			usage += fmt.Sprintf(" (default %q)", f.DefValue)
+		}
+		_, _ = fmt.Fprintf(w, "%s\n    \t%s\n", line, strings.ReplaceAll(usage, "\n", "\n    \t"))
+	})
+}
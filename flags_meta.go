@@ -0,0 +1,87 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"errors"
+	"flag"
+	"runtime"
+	"sync"
+	"weak"
+)
+
+// flagMeta holds what FlagOptions know about a whole flag.FlagSet, beyond its single flags
+// (those are annotated with annotatedValue). It is used once the arguments are parsed.
+type flagMeta struct {
+	aliases map[string]string // alias -> name of the flag sharing its value
+	sources flagSources
+	checks  []flagCheck
+	gnu     bool
+	args    []*flagArg
+}
+
+// flagMetas maps the FlagSets to their flagMeta without keeping them alive.
+var flagMetas sync.Map // weak.Pointer[flag.FlagSet] -> *flagMeta
+
+// metaOf returns the flagMeta of the FlagSet, creating it when needed.
+func metaOf(flags *flag.FlagSet) *flagMeta {
+	key := weak.Make(flags)
+	if m, ok := flagMetas.Load(key); ok {
+		return m.(*flagMeta)
+	}
+	m, loaded := flagMetas.LoadOrStore(key, &flagMeta{aliases: make(map[string]string)})
+	if !loaded {
+		runtime.AddCleanup(flags, func(key weak.Pointer[flag.FlagSet]) { flagMetas.Delete(key) }, key)
+	}
+	return m.(*flagMeta)
+}
+
+// lookupMeta returns the flagMeta of the FlagSet or nil if no FlagOption needed one.
+func lookupMeta(flags *flag.FlagSet) *flagMeta {
+	m, ok := flagMetas.Load(weak.Make(flags))
+	if !ok {
+		return nil
+	}
+	return m.(*flagMeta)
+}
+
+// parseFlags parses the arguments, completes the values with the help of the flagMeta and checks them.
+// Errors are returned as a [*UsageError]; all failed checks are reported together.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	m := lookupMeta(flags)
+	if m == nil {
+		return usageError(flags.Parse(args))
+	}
+	parse := flags.Parse
+	if m.gnu {
+		parse = func(args []string) error { return parseGNU(flags, args) }
+	}
+	if err := parse(args); err != nil {
+		return usageError(err)
+	}
+	given := m.setOnCommandLine(flags)
+	if err := m.sources.resolve(flags, m, given); err != nil {
+		return usageError(err)
+	}
+	errs := []error{m.bindArgs(flags.Args())}
+	for _, check := range m.checks {
+		errs = append(errs, check(flags, given))
+	}
+	return usageError(errors.Join(errs...))
+}
+
+// primary returns the name of the flag an alias shares its value with, or the name itself.
+func (m *flagMeta) primary(name string) string {
+	if p, ok := m.aliases[name]; ok {
+		return p
+	}
+	return name
+}
+
+// setOnCommandLine returns the primary names of the flags given in the arguments.
+func (m *flagMeta) setOnCommandLine(flags *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[m.primary(f.Name)] = true })
+	return set
+}
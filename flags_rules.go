@@ -0,0 +1,176 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// flagCheck checks the values of the flags once they are parsed. The given flags are those set
+// in the arguments or from another source, see [FlagsApp], by their primary names.
+type flagCheck func(flags *flag.FlagSet, given map[string]bool) error
+
+// addCheck adds a check of the flags defined earlier under the names.
+func addCheck(flags *flag.FlagSet, names []string, check flagCheck) {
+	for _, name := range names {
+		if flags.Lookup(name) == nil {
+			panic(fmt.Sprintf("x: flag %q must be defined before it is checked", name))
+		}
+	}
+	m := metaOf(flags)
+	m.checks = append(m.checks, check)
+}
+
+// FlagRequired requires the flags defined earlier under the names to be given,
+// in the arguments or from another source.
+// Like all checks, it is done after parsing and its failures are reported together in one [*UsageError].
+// To restrict a flag to a set of values, use [FlagEnum].
+func FlagRequired(names ...string) FlagOption {
+	return func(flags *flag.FlagSet) {
+		addCheck(flags, names, func(flags *flag.FlagSet, given map[string]bool) error {
+			m := lookupMeta(flags)
+			var errs []error
+			for _, name := range names {
+				if !given[m.primary(name)] {
+					errs = append(errs, fmt.Errorf("flag -%s is required", name))
+				}
+			}
+			return errors.Join(errs...)
+		})
+	}
+}
+
+// FlagRange requires the value of the flag defined earlier under the name, if given, to be between lo and hi.
+// T must be the type of the flag, e.g. time.Duration for a duration flag.
+func FlagRange[T cmp.Ordered](name string, lo, hi T) FlagOption {
+	return func(flags *flag.FlagSet) {
+		addCheck(flags, []string{name}, func(flags *flag.FlagSet, given map[string]bool) error {
+			if !given[lookupMeta(flags).primary(name)] {
+				return nil
+			}
+			v := flagValue[T](flags, name)
+			if v < lo || v > hi {
+				return fmt.Errorf("flag -%s must be between %v and %v, got %v", name, lo, hi, v)
+			}
+			return nil
+		})
+		flagValue[T](flags, name) // fail early
+	}
+}
+
+// FlagMatch requires the value of the flag defined earlier under the name, if given, to match the regular expression.
+// Every value of a []string flag must match.
+func FlagMatch(name, expr string) FlagOption {
+	re := regexp.MustCompile(expr)
+	return func(flags *flag.FlagSet) {
+		addCheck(flags, []string{name}, func(flags *flag.FlagSet, given map[string]bool) error {
+			if !given[lookupMeta(flags).primary(name)] {
+				return nil
+			}
+			var errs []error
+			for _, v := range flagStrings(flags, name) {
+				if !re.MatchString(v) {
+					errs = append(errs, fmt.Errorf("flag -%s must match %s, got %q", name, expr, v))
+				}
+			}
+			return errors.Join(errs...)
+		})
+	}
+}
+
+// FlagExists requires the file named by the flag defined earlier under the name, if given, to exist.
+// "-", standing for the standard input or output (see [DynamicReader]), is accepted.
+// The flag is marked as a file name, like with [FlagFile].
+func FlagExists(name string) FlagOption {
+	return func(flags *flag.FlagSet) {
+		FlagFile(name)(flags)
+		addCheck(flags, []string{name}, func(flags *flag.FlagSet, given map[string]bool) error {
+			if !given[lookupMeta(flags).primary(name)] {
+				return nil
+			}
+			var errs []error
+			for _, v := range flagStrings(flags, name) {
+				if v == "-" {
+					continue
+				}
+				if _, err := os.Stat(v); err != nil {
+					errs = append(errs, fmt.Errorf("flag -%s: %w", name, err))
+				}
+			}
+			return errors.Join(errs...)
+		})
+	}
+}
+
+// FlagsExclusive allows at most one of the flags defined earlier under the names to be given.
+func FlagsExclusive(names ...string) FlagOption {
+	return func(flags *flag.FlagSet) {
+		addCheck(flags, names, func(flags *flag.FlagSet, given map[string]bool) error {
+			if used := givenFlags(flags, given, names); len(used) > 1 {
+				return fmt.Errorf("flags %s cannot be used together", strings.Join(used, ", "))
+			}
+			return nil
+		})
+	}
+}
+
+// FlagsTogether requires the flags defined earlier under the names to be given all together or not at all.
+func FlagsTogether(names ...string) FlagOption {
+	return func(flags *flag.FlagSet) {
+		addCheck(flags, names, func(flags *flag.FlagSet, given map[string]bool) error {
+			used := givenFlags(flags, given, names)
+			if len(used) == 0 || len(used) == len(names) {
+				return nil
+			}
+			var missing []string
+			for _, name := range names {
+				if !given[lookupMeta(flags).primary(name)] {
+					missing = append(missing, "-"+name)
+				}
+			}
+			return fmt.Errorf("flags %s must be used together with %s", strings.Join(used, ", "), strings.Join(missing, ", "))
+		})
+	}
+}
+
+// givenFlags returns the names of the given flags, each with a dash.
+func givenFlags(flags *flag.FlagSet, given map[string]bool, names []string) []string {
+	var used []string
+	for _, name := range names {
+		if given[lookupMeta(flags).primary(name)] {
+			used = append(used, "-"+name)
+		}
+	}
+	return used
+}
+
+// flagValue returns the value of the named flag. It panics if the flag is not of type T.
+func flagValue[T any](flags *flag.FlagSet, name string) T {
+	g, ok := flags.Lookup(name).Value.(flag.Getter)
+	if !ok {
+		panic(fmt.Sprintf("x: flag %q has no typed value", name))
+	}
+	v, ok := g.Get().(T)
+	if !ok {
+		panic(fmt.Sprintf("x: flag %q is a %T, not a %T", name, g.Get(), v))
+	}
+	return v
+}
+
+// flagStrings returns the values of the named flag as strings.
+func flagStrings(flags *flag.FlagSet, name string) []string {
+	v := flags.Lookup(name).Value
+	if g, ok := v.(flag.Getter); ok {
+		if vs, ok := g.Get().([]string); ok {
+			return vs
+		}
+	}
+	return []string{v.String()}
+}
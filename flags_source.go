@@ -0,0 +1,193 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/teghnet/x/app"
+)
+
+// Kinds of [FlagSource], from the lowest to the highest precedence.
+const (
+	FlagSourceDefault = "default"
+	FlagSourceConfig  = "config"
+	FlagSourceDotEnv  = "dotenv"
+	FlagSourceEnv     = "env"
+	FlagSourceArgs    = "args"
+)
+
+// FlagSource tells where the value of a flag came from, see [FlagsSources].
+type FlagSource struct {
+	Kind string
+	// Name is the config file, the .env file or the environment variable the value was read from.
+	Name string
+}
+
+func (s FlagSource) String() string {
+	if s.Name == "" {
+		return s.Kind
+	}
+	return s.Kind + " " + s.Name
+}
+
+// flagSources are the places the values of flags not given in the arguments are read from.
+type flagSources struct {
+	config    string
+	dotEnv    string
+	env       bool
+	envPrefix string
+	report    map[string]FlagSource
+}
+
+// FlagsConfig makes flags not given in the arguments fall back to the values in a JSON config file:
+// an object with flag names as keys and strings, numbers, booleans or arrays of those as values.
+// A missing file is not an error.
+func FlagsConfig(name string) FlagOption {
+	return func(flags *flag.FlagSet) { metaOf(flags).sources.config = name }
+}
+
+// FlagsDotEnv makes flags not given in the arguments fall back to the variables of a .env file,
+// named like with [FlagsEnv]. A missing file is not an error.
+func FlagsDotEnv(name string) FlagOption {
+	return func(flags *flag.FlagSet) { metaOf(flags).sources.dotEnv = name }
+}
+
+// FlagsEnv makes flags not given in the arguments fall back to environment variables named after them:
+// the prefix followed by the name in upper case with dashes and other non-word characters replaced
+// by underscores, e.g. APP_DB_HOST for the flag -db-host with the prefix "APP_".
+func FlagsEnv(prefix string) FlagOption {
+	return func(flags *flag.FlagSet) {
+		s := &metaOf(flags).sources
+		s.env, s.envPrefix = true, prefix
+	}
+}
+
+// FlagsApp sets up all fallbacks of flags for the [app.App] the context was derived from:
+// the JSON config file named under its ConfigPath, the .env file in the working directory
+// and the environment variables prefixed with its name ("mytool" gives MYTOOL_).
+// Without an app or its name, only the .env file is used: unprefixed variables like USER or PATH
+// would silently fill unrelated flags. Add [FlagsEnv] with an explicit prefix to use the environment too.
+//
+// The values are resolved in this order, each overriding the previous one:
+// defaults, config file, .env file, environment, command line.
+func FlagsApp(ctx context.Context, config string) FlagOption {
+	return func(flags *flag.FlagSet) {
+		s := &metaOf(flags).sources
+		s.dotEnv = ".env"
+		if a, ok := app.FromContext(ctx); ok {
+			s.config = a.ConfigPath(config)
+			if a.Name != "" {
+				s.env, s.envPrefix = true, envPrefix(a.Name)
+			}
+		}
+	}
+}
+
+// FlagsSources fills the map with the source of the final value of every flag once the arguments are parsed.
+func FlagsSources(sources map[string]FlagSource) FlagOption {
+	return func(flags *flag.FlagSet) { metaOf(flags).sources.report = sources }
+}
+
+// envName returns the environment variable of the flag.
+func (s *flagSources) envName(flag string) string {
+	return s.envPrefix + strings.ToUpper(nonWord.ReplaceAllString(flag, "_"))
+}
+
+// resolve sets the flags not given in the arguments from the highest-precedence source having a value for them
+// and adds them to the given flags.
+func (s *flagSources) resolve(flags *flag.FlagSet, m *flagMeta, given map[string]bool) error {
+	config, err := readFlagsConfig(s.config)
+	if err != nil {
+		return err
+	}
+	dotEnv := map[string]string{}
+	if s.dotEnv != "" {
+		if dotEnv, err = godotenv.Read(s.dotEnv); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%s: %w", s.dotEnv, err)
+		}
+	}
+	set := maps.Clone(given)
+	var errs []error
+	flags.VisitAll(func(f *flag.Flag) {
+		name := m.primary(f.Name)
+		src := FlagSource{Kind: FlagSourceDefault}
+		switch {
+		case set[name]:
+			src.Kind = FlagSourceArgs
+		case f.Name != name:
+			// an alias shares the value and the source of its flag
+			return
+		default:
+			var values []string
+			if v, ok := os.LookupEnv(s.envName(name)); ok && s.env {
+				src, values = FlagSource{FlagSourceEnv, s.envName(name)}, []string{v}
+			} else if v, ok := dotEnv[s.envName(name)]; ok {
+				src, values = FlagSource{FlagSourceDotEnv, s.dotEnv}, []string{v}
+			} else if vs, ok := config[name]; ok {
+				src, values = FlagSource{FlagSourceConfig, s.config}, vs
+			}
+			given[name] = given[name] || len(values) > 0
+			for _, v := range values {
+				if err := f.Value.Set(v); err != nil {
+					errs = append(errs, fmt.Errorf("invalid value %q for flag -%s from %s: %w", v, name, src, err))
+				}
+			}
+		}
+		if s.report != nil {
+			s.report[f.Name] = src
+		}
+	})
+	if s.report != nil {
+		for alias, name := range m.aliases {
+			s.report[alias] = s.report[name]
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// readFlagsConfig reads the values of flags from a JSON config file, all as strings.
+func readFlagsConfig(name string) (map[string][]string, error) {
+	if name == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("config %s: %w", name, err)
+	}
+	config := make(map[string][]string, len(raw))
+	for k, v := range raw {
+		vs, ok := v.([]any)
+		if !ok {
+			vs = []any{v}
+		}
+		for _, v := range vs {
+			switch v := v.(type) {
+			case string, json.Number, bool:
+				config[k] = append(config[k], fmt.Sprint(v))
+			default:
+				return nil, fmt.Errorf("config %s: unsupported value of %q: %v", name, k, v)
+			}
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// FlagsStruct defines a flag for every tagged field of the struct p points to:
+//
+//	type options struct {
+//		Input   string        `flag:"input,i" usage:"input file" default:"-"`
+//		Timeout time.Duration `flag:"timeout" usage:"time limit"`
+//		Level   slog.Level    `flag:"level" usage:"log level"`
+//		DB      struct {
+//			Host string `flag:"host" usage:"database host"`
+//		} `flag:"db"`
+//		Shared  // embedded, its flags are defined as if they were fields of options
+//	}
+//
+// The "flag" tag holds the name of the flag followed by its aliases, which are defined as flags
+// sharing the value. Fields may be of any type supported by [Flag] or implement [encoding.TextUnmarshaler].
+// The "default" tag sets zero fields before parsing. The flags of a nested struct are prefixed
+// with its name from the "flag" tag (or its lowercase field name) and a dash ("db-host"), unless a "prefix" tag
+// says otherwise; embedded structs, which must be of exported types, are not prefixed.
+// Untagged fields and fields tagged `flag:"-"` are ignored.
+// FlagsStruct panics if p is not a pointer to a struct or a tag cannot be used, like [Flag] does.
+func FlagsStruct(p any) FlagOption {
+	v := reflect.ValueOf(p)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("x: FlagsStruct needs a pointer to a struct, got %T", p))
+	}
+	return func(flags *flag.FlagSet) {
+		structFlags(flags, v.Elem(), "")
+	}
+}
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// structFlags defines the flags of the fields of the struct value v, prefixing their names.
+func structFlags(flags *flag.FlagSet, v reflect.Value, prefix string) {
+	t := v.Type()
+	for i := range t.NumField() {
+		sf, fv := t.Field(i), v.Field(i)
+		tag, tagged := sf.Tag.Lookup("flag")
+		if !sf.IsExported() || tag == "-" {
+			continue
+		}
+		names := strings.Split(tag, ",")
+		if fv.Kind() == reflect.Struct && !fv.Addr().Type().Implements(textUnmarshalerType) {
+			sub, ok := sf.Tag.Lookup("prefix")
+			switch {
+			case ok:
+			case sf.Anonymous:
+				sub = ""
+			case tagged:
+				sub = names[0] + "-"
+			default:
+				sub = strings.ToLower(sf.Name) + "-"
+			}
+			structFlags(flags, fv, prefix+sub)
+			continue
+		}
+		if !tagged {
+			continue
+		}
+		ptr, usage := fv.Addr().Interface(), sf.Tag.Get("usage")
+		if def, ok := sf.Tag.Lookup("default"); ok && fv.IsZero() {
+			scratch := flag.NewFlagSet("", flag.ContinueOnError)
+			fieldFlag(ptr, "default", "")(scratch)
+			if err := scratch.Set("default", def); err != nil {
+				panic(fmt.Sprintf("x: default of field %s: %v", sf.Name, err))
+			}
+		}
+		for i, name := range names {
+			if name = strings.TrimSpace(name); name == "" {
+				panic(fmt.Sprintf("x: empty flag name in the tag of field %s", sf.Name))
+			}
+			fieldFlag(ptr, prefix+name, usage)(flags)
+			if i > 0 {
+				metaOf(flags).aliases[prefix+name] = prefix + strings.TrimSpace(names[0])
+			}
+		}
+	}
+}
+
+// fieldFlag defines a flag for a pointer to a struct field.
+func fieldFlag(p any, name, usage string) FlagOption {
+	switch v := p.(type) {
+	case encoding.TextUnmarshaler:
+		return func(flags *flag.FlagSet) { flags.Var(textValue{v}, name, usage) }
+	case *bool:
+		return Flag(v, name, usage)
+	case *int:
+		return Flag(v, name, usage)
+	case *int64:
+		return Flag(v, name, usage)
+	case *uint:
+		return Flag(v, name, usage)
+	case *uint64:
+		return Flag(v, name, usage)
+	case *string:
+		return Flag(v, name, usage)
+	case *float64:
+		return Flag(v, name, usage)
+	case *[]string:
+		return Flag(v, name, usage)
+	case *time.Duration:
+		return Flag(v, name, usage)
+	}
+	panic(fmt.Sprintf("x: unsupported type of flag %q: %T", name, p))
+}
+
+// textValue is a flag.Value of a field implementing encoding.TextUnmarshaler, and possibly encoding.TextMarshaler.
+// Unlike with [FlagText], the value needs no separate default.
+type textValue struct {
+	p encoding.TextUnmarshaler
+}
+
+func (v textValue) Set(s string) error {
+	return v.p.UnmarshalText([]byte(s))
+}
+
+func (v textValue) String() string {
+	m, ok := v.p.(encoding.TextMarshaler)
+	if !ok {
+		return ""
+	}
+	b, err := m.MarshalText()
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func (v textValue) Get() any {
+	return v.p
+}
@@ -0,0 +1,296 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x_test
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teghnet/x"
+)
+
+type SharedOptions struct {
+	Verbose bool `flag:"verbose,v" usage:"verbose output"`
+}
+
+type structOptions struct {
+	SharedOptions
+	Input   string        `flag:"input,i" usage:"input file" default:"-"`
+	Jobs    int           `flag:"j" usage:"parallel jobs" default:"4"`
+	Timeout time.Duration `flag:"timeout" usage:"time limit"`
+	Tags    []string      `flag:"tag" usage:"tags"`
+	Level   slog.Level    `flag:"level" usage:"log level"`
+	DB      struct {
+		Host string `flag:"host" usage:"database host" default:"localhost"`
+		Port uint   `flag:"port" usage:"database port"`
+	} `flag:"db"`
+	Cache struct {
+		Size int64 `flag:"size" usage:"cache size"`
+	}
+	Ignored string
+	Skipped string `flag:"-"`
+}
+
+func TestFlagsStruct(t *testing.T) {
+	var opts structOptions
+	err := x.FlagsParse([]string{
+		"-v", "-i", "in.json", "-timeout", "2s", "-tag", "a,b", "-tag", "c",
+		"-level", "warn", "-db-port", "5432", "-cache-size", "10",
+	}, x.FlagsStruct(&opts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !opts.Verbose || opts.Input != "in.json" || opts.Jobs != 4 || opts.Timeout != 2*time.Second ||
+		!slices.Equal(opts.Tags, []string{"a", "b", "c"}) || opts.Level != slog.LevelWarn ||
+		opts.DB.Host != "localhost" || opts.DB.Port != 5432 || opts.Cache.Size != 10 {
+		t.Errorf("FlagsStruct() = %+v", opts)
+	}
+
+	opts = structOptions{}
+	if err := x.FlagsParse([]string{"-input", "x"}, x.FlagsStruct(&opts)); err != nil || opts.Input != "x" {
+		t.Errorf("FlagsStruct(-input) = %q, %v", opts.Input, err)
+	}
+	for _, args := range [][]string{{"-Ignored", "x"}, {"-Skipped", "x"}, {"-host", "x"}, {"-level", "loud"}} {
+		if err := x.FlagsParse(args, x.FlagsStruct(&structOptions{})); err == nil {
+			t.Errorf("FlagsStruct(%q): want error", args)
+		}
+	}
+}
+
+func TestFlagsSources(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "tool.json")
+	dotEnv := filepath.Join(dir, ".env")
+	for name, content := range map[string]string{
+		config: `{"input": "config.json", "j": 8, "verbose": true, "tag": ["a", "b"], "level": "error"}`,
+		dotEnv: "TOOL_INPUT=dotenv.json\nTOOL_LEVEL=warn\n",
+	} {
+		if err := os.WriteFile(name, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("TOOL_LEVEL", "debug")
+	t.Setenv("TOOL_DB_PORT", "5432")
+	t.Setenv("TOOL_TIMEOUT", "1m")
+
+	var opts structOptions
+	sources := make(map[string]x.FlagSource)
+	err := x.FlagsParse([]string{"-timeout", "2s"},
+		x.FlagsStruct(&opts), x.FlagsConfig(config), x.FlagsDotEnv(dotEnv), x.FlagsEnv("TOOL_"), x.FlagsSources(sources))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Input != "dotenv.json" || opts.Jobs != 8 || !opts.Verbose || !slices.Equal(opts.Tags, []string{"a", "b"}) ||
+		opts.Level != slog.LevelDebug || opts.DB.Port != 5432 || opts.Timeout != 2*time.Second || opts.DB.Host != "localhost" {
+		t.Errorf("FlagsParse() = %+v", opts)
+	}
+	for name, want := range map[string]x.FlagSource{
+		"input":   {Kind: x.FlagSourceDotEnv, Name: dotEnv},
+		"i":       {Kind: x.FlagSourceDotEnv, Name: dotEnv},
+		"j":       {Kind: x.FlagSourceConfig, Name: config},
+		"level":   {Kind: x.FlagSourceEnv, Name: "TOOL_LEVEL"},
+		"timeout": {Kind: x.FlagSourceArgs},
+		"db-host": {Kind: x.FlagSourceDefault},
+	} {
+		if got := sources[name]; got != want {
+			t.Errorf("source of -%s = %v, want %v", name, got, want)
+		}
+	}
+
+	t.Chdir(t.TempDir())
+	t.Setenv("INPUT", "env.json")
+	opts = structOptions{}
+	if err := x.FlagsParse(nil, x.FlagsStruct(&opts), x.FlagsApp(t.Context(), "tool.json")); err != nil || opts.Input != "-" {
+		t.Errorf("FlagsApp() without an app: -input = %q, %v, want the default", opts.Input, err)
+	}
+
+	t.Setenv("TOOL_J", "many")
+	if err := x.FlagsParse(nil, x.FlagsStruct(&structOptions{}), x.FlagsEnv("TOOL_")); !errors.As(err, new(*x.UsageError)) {
+		t.Errorf("FlagsParse(TOOL_J=many) error = %v, want a usage error", err)
+	}
+}
+
+func TestFlagsChecks(t *testing.T) {
+	existing := filepath.Join(t.TempDir(), "in.json")
+	if err := os.WriteFile(existing, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	parse := func(args ...string) error {
+		var opts structOptions
+		var user, password, format string
+		return x.FlagsParse(args,
+			x.FlagsStruct(&opts),
+			x.Flag(&format, "format", "output format"),
+			x.FlagEnum("format", "json", "xml"),
+			x.Flag(&user, "user", "user name"),
+			x.Flag(&password, "password", "password"),
+			x.FlagRequired("input"),
+			x.FlagRange("j", 1, 16),
+			x.FlagRange("timeout", time.Second, time.Hour),
+			x.FlagMatch("tag", `^[a-z]+$`),
+			x.FlagExists("input"),
+			x.FlagsExclusive("verbose", "level"),
+			x.FlagsTogether("user", "password"),
+		)
+	}
+	if err := parse("-i", existing, "-j", "16", "-tag", "a,b", "-user", "u", "-password", "p", "-format", "xml"); err != nil {
+		t.Errorf("valid flags: %v", err)
+	}
+	err := parse("-i", "", "-j", "0", "-format", "yaml", "-timeout", "1ms", "-tag", "a,B", "-v", "-level", "warn", "-user", "u")
+	if !errors.As(err, new(*x.UsageError)) {
+		t.Fatalf("error = %v, want a usage error", err)
+	}
+	for _, want := range []string{
+		"flag -j must be between 1 and 16, got 0",
+		`flag -format must be one of json, xml, got "yaml"`,
+		"flag -timeout must be between 1s and 1h0m0s, got 1ms",
+		`flag -tag must match ^[a-z]+$, got "B"`,
+		"flag -input: stat : ",
+		"flags -verbose, -level cannot be used together",
+		"flags -user must be used together with -password",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %v\nwant %q", err, want)
+		}
+	}
+	if err := parse(); err == nil || !strings.Contains(err.Error(), "flag -input is required") {
+		t.Errorf("error = %v, want -input required", err)
+	}
+
+	for _, gnu := range []bool{false, true} {
+		var opts struct {
+			Format string `flag:"format,f" usage:"output format"`
+		}
+		flags := []x.FlagOption{x.FlagsStruct(&opts), x.FlagEnum("format", "json", "xml")}
+		if gnu {
+			flags = append(flags, x.FlagsGNU())
+		}
+		err := x.FlagsParse([]string{"-f", "yaml"}, flags...)
+		if !errors.As(err, new(*x.UsageError)) || !strings.Contains(err.Error(), `flag -format must be one of json, xml, got "yaml"`) {
+			t.Errorf("FlagEnum(alias, gnu=%v) error = %v", gnu, err)
+		}
+	}
+}
+
+func TestFlagsGNU(t *testing.T) {
+	var opts struct {
+		Output  string   `flag:"output,o" usage:"output file"`
+		Verbose bool     `flag:"verbose,v" usage:"verbose output"`
+		All     bool     `flag:"a" usage:"all"`
+		Color   bool     `flag:"color" usage:"colored output" default:"true"`
+		Tags    []string `flag:"tag,t" usage:"tags"`
+	}
+	args, err := x.FlagsArgs([]string{"in", "-vao", "out", "--no-color", "x", "--tag=a", "-tb", "--", "-z"},
+		x.FlagsStruct(&opts), x.FlagsGNU())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Output != "out" || !opts.Verbose || !opts.All || opts.Color || !slices.Equal(opts.Tags, []string{"a", "b"}) {
+		t.Errorf("FlagsGNU() = %+v", opts)
+	}
+	if want := []string{"in", "x", "-z"}; !slices.Equal(args, want) {
+		t.Errorf("FlagsGNU() args = %q, want %q", args, want)
+	}
+
+	opts.Output, opts.Verbose = "", false
+	args, err = x.FlagsArgs([]string{"-", "--output", "-v", "--", "--tag"}, x.FlagsStruct(&opts), x.FlagsGNU())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"-", "--tag"}; !slices.Equal(args, want) || opts.Output != "-v" || opts.Verbose {
+		t.Errorf("FlagsGNU() args = %q, -output = %q, -verbose = %v, want %q, -v, false", args, opts.Output, opts.Verbose, want)
+	}
+
+	for _, args := range [][]string{{"-verbose"}, {"--o", "x"}, {"--output"}, {"-x"}, {"--no-output"}} {
+		if err := x.FlagsParse(args, x.FlagsStruct(&opts), x.FlagsGNU()); !errors.As(err, new(*x.UsageError)) {
+			t.Errorf("FlagsGNU(%q) error = %v, want a usage error", args, err)
+		}
+	}
+}
+
+func TestArg(t *testing.T) {
+	var verbose bool
+	var src, dst string
+	var n int
+	var rest []string
+	opts := func() []x.FlagOption {
+		verbose, src, dst, n, rest = false, "", "", 0, nil
+		return []x.FlagOption{
+			x.Flag(&verbose, "v", "verbose"),
+			x.Arg(&src, "src", "source file"),
+			x.Arg(&n, "n", "number of copies"),
+			x.ArgOptional(&dst, "dst", "destination file"),
+			x.ArgOptional(&rest, "extra", "extra files"),
+		}
+	}
+	args, err := x.FlagsArgs([]string{"-v", "a", "2", "b", "c", "d"}, opts()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verbose || src != "a" || n != 2 || dst != "b" || !slices.Equal(rest, []string{"c", "d"}) {
+		t.Errorf("Arg() = %v %q %d %q %q", verbose, src, n, dst, rest)
+	}
+	if want := []string{"a", "2", "b", "c", "d"}; !slices.Equal(args, want) {
+		t.Errorf("FlagsArgs() = %q, want %q", args, want)
+	}
+	if err := x.FlagsParse([]string{"a", "1"}, opts()...); err != nil || dst != "" || rest != nil {
+		t.Errorf("Arg(optional) = %q %q, %v", dst, rest, err)
+	}
+
+	err = x.FlagsParse([]string{"-v"}, opts()...)
+	if !errors.As(err, new(*x.UsageError)) || !strings.Contains(err.Error(), "missing argument <src>") ||
+		!strings.Contains(err.Error(), "missing argument <n>") {
+		t.Errorf("missing args error = %v", err)
+	}
+	err = x.FlagsParse([]string{"a", "two"}, opts()...)
+	if err == nil || !strings.Contains(err.Error(), `invalid value "two" for argument <n>`) {
+		t.Errorf("invalid arg error = %v", err)
+	}
+	var one string
+	err = x.FlagsParse([]string{"a", "b c"}, x.Arg(&one, "one", "a single argument"))
+	if err == nil || !strings.Contains(err.Error(), `unexpected arguments: 'b c'`) {
+		t.Errorf("unexpected args error = %v", err)
+	}
+
+	reg := x.NewRegistry("tool")
+	reg.Handle("copy", func([]string) x.Command { return nil }, x.CommandFlags(opts))
+	var out strings.Builder
+	if err := reg.Lookup("copy").Usage(&out, "tool"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Usage: tool copy [flags] <src> <n> [<dst>] [<extra>...]\n",
+		"Arguments:\n  src\n    \tsource file\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("usage:\n%s\nwant %q", out.String(), want)
+		}
+	}
+}
+
+func TestFlagAnnotations_Usage(t *testing.T) {
+	reg := x.NewRegistry("tool")
+	reg.Handle("convert", func([]string) x.Command { return nil }, x.CommandFlags(func() []x.FlagOption {
+		var in, format string
+		var n int
+		return []x.FlagOption{
+			x.Flag(&in, "i", "input file"), x.FlagFile("i"),
+			x.Flag(&format, "format", "output format"), x.FlagEnum("format", "json", "xml"),
+			x.Flag(&n, "n", "count"), x.FlagExists("i"),
+		}
+	}))
+	var out strings.Builder
+	if err := reg.Lookup("convert").Usage(&out, "tool"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "panic") || !strings.Contains(out.String(), "-format value\n    \toutput format") {
+		t.Errorf("usage:\n%s", out.String())
+	}
+}
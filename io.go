@@ -4,6 +4,7 @@
 package x
 
 import (
+	"context"
 	"io"
 	"log"
 	"os"
@@ -76,3 +77,34 @@ func DynamicWriter(name string, append bool) (io.WriteCloser, error) {
 	}
 	return os.OpenFile(name, flag, 0600)
 }
+
+type stdoutKey struct{}
+type stderrKey struct{}
+
+// WithStdout returns a copy of ctx in which [Stdout] returns w.
+func WithStdout(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, stdoutKey{}, w)
+}
+
+// WithStderr returns a copy of ctx in which [Stderr] returns w.
+func WithStderr(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, stderrKey{}, w)
+}
+
+// Stdout returns the writer a Command should use for its output.
+// It is os.Stdout unless the caller (e.g. [Batch]) redirected it with [WithStdout].
+func Stdout(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(stdoutKey{}).(io.Writer); ok {
+		return w
+	}
+	return os.Stdout
+}
+
+// Stderr returns the writer a Command should use for diagnostics.
+// It is os.Stderr unless the caller redirected it with [WithStderr].
+func Stderr(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(stderrKey{}).(io.Writer); ok {
+		return w
+	}
+	return os.Stderr
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/teghnet/x/app"
+)
+
+// Exit codes of [Main] for the errors recognized by [ExitCode].
+const (
+	ExitOK          = 0
+	ExitFailure     = 1
+	ExitUsage       = 2
+	ExitNotFound    = 127
+	ExitInterrupted = 130
+)
+
+// ExitCoder is implemented by errors choosing the exit code of the process, like [*exec.ExitError].
+type ExitCoder interface {
+	error
+	ExitCode() int
+}
+
+// UsageError reports a command invoked incorrectly, e.g. with an unknown flag.
+type UsageError struct {
+	Err error
+}
+
+func (e *UsageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+// SignalError is the cancellation cause of the context of [Main] when the process receives a signal.
+// It matches [ErrInterrupted].
+type SignalError struct {
+	Signal os.Signal
+}
+
+func (e *SignalError) Error() string {
+	return "received " + e.Signal.String()
+}
+
+func (e *SignalError) Is(target error) bool {
+	return target == ErrInterrupted
+}
+
+// ExitCode returns the process exit code for err:
+// the code of an [ExitCoder], 128+n for [*SignalError] of signal n, [ExitUsage] for a [*UsageError] or a missing command,
+// [ExitNotFound] for [ErrCommandNotFound], [ExitInterrupted] for other interruptions and cancellations,
+// [ExitFailure] for the rest and [ExitOK] for nil or [flag.ErrHelp].
+func ExitCode(err error) int {
+	var (
+		ec  ExitCoder
+		se  *SignalError
+		ue  *UsageError
+		uce *UnknownCommandError
+	)
+	switch {
+	case err == nil || errors.Is(err, flag.ErrHelp):
+		return ExitOK
+	case errors.As(err, &ec) && ec.ExitCode() >= 0:
+		return ec.ExitCode()
+	case errors.As(err, &se):
+		if s, ok := se.Signal.(syscall.Signal); ok {
+			return 128 + int(s)
+		}
+		return ExitInterrupted
+	case errors.As(err, &ue), errors.As(err, &uce) && uce.Name == "":
+		return ExitUsage
+	case errors.Is(err, ErrCommandNotFound):
+		return ExitNotFound
+	case errors.Is(err, ErrInterrupted), errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	}
+	return ExitFailure
+}
+
+// MainOption customizes [Main].
+type MainOption func(*mainConfig)
+
+// MainApp adds options of the [app.App] created by Main, e.g. [app.DefaultName].
+func MainApp(opts ...app.Option) MainOption {
+	return func(c *mainConfig) { c.app = append(c.app, opts...) }
+}
+
+// MainArgs sets the command-line arguments. By default, os.Args[1:] are used.
+func MainArgs(args []string) MainOption {
+	return func(c *mainConfig) { c.args = args }
+}
+
+// MainContext sets the parent of the app context, e.g. one carrying [WithStdout] and [WithStderr].
+func MainContext(ctx context.Context) MainOption {
+	return func(c *mainConfig) { c.ctx = ctx }
+}
+
+// MainExit replaces os.Exit, e.g. in tests.
+func MainExit(exit func(code int)) MainOption {
+	return func(c *mainConfig) { c.exit = exit }
+}
+
+type mainConfig struct {
+	app  []app.Option
+	args []string
+	ctx  context.Context
+	exit func(int)
+}
+
+// interruptClaims counts the commands handling interrupts themselves, like [Shell].
+// [Main] ignores SIGINT while it is positive.
+var interruptClaims atomic.Int32
+
+// Main runs the command selected by the command-line arguments through [BatchOnce] and exits the process.
+//
+// The command gets the context of an [app.App] configured from the environment (see [app.NewConf]),
+// named after the executable unless set otherwise. SIGINT and SIGTERM cancel it with a [*SignalError];
+// a second signal exits at once. An error of the command is printed to [Stderr] and mapped to the exit code by [ExitCode].
+func Main(cs CommandSelector, opts ...MainOption) {
+	conf := mainConfig{args: os.Args[1:], ctx: context.Background(), exit: os.Exit}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	conf.exit(conf.run(cs))
+}
+
+func (c mainConfig) run(cs CommandSelector) int {
+	ctx, cancel := context.WithCancelCause(c.ctx)
+	defer cancel(nil)
+	a, err := app.NewConf[app.DefaultApp]()
+	if err != nil {
+		return c.fail(ctx, err)
+	}
+	a.Init(append(c.app, app.DefaultName(filepath.Base(os.Args[0])), app.WithContext(ctx))...)
+	defer ClosePrint(a)
+
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	defer func() {
+		signal.Stop(sigs)
+		close(done)
+	}()
+	go c.signals(ctx, done, cancel, sigs)
+
+	err = CancelCause()(BatchOnce(c.args, cs))(&a.App)
+	return c.fail(ctx, err)
+}
+
+// signals cancels the context on the first signal and exits on the second, until done is closed.
+func (c mainConfig) signals(ctx context.Context, done <-chan struct{}, cancel context.CancelCauseFunc, sigs <-chan os.Signal) {
+	cancelled := false
+	for {
+		select {
+		case <-done:
+			return
+		case sig := <-sigs:
+			err := &SignalError{Signal: sig}
+			switch {
+			case cancelled:
+				_, _ = fmt.Fprintf(Stderr(ctx), "error: %v again, exiting\n", err)
+				c.exit(ExitCode(err))
+				return
+			case sig == os.Interrupt && interruptClaims.Load() > 0:
+				// handled by the running command
+			default:
+				cancelled = true
+				cancel(err)
+			}
+		}
+	}
+}
+
+// fail prints err, if any, and returns the exit code.
+func (c mainConfig) fail(ctx context.Context, err error) int {
+	code := ExitCode(err)
+	if code != ExitOK {
+		_, _ = fmt.Fprintf(Stderr(ctx), "error: %v\n", err)
+	}
+	return code
+}
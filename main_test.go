@@ -0,0 +1,117 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/teghnet/x"
+	"github.com/teghnet/x/app"
+)
+
+type exitError int
+
+func (e exitError) Error() string { return fmt.Sprintf("exit %d", int(e)) }
+func (e exitError) ExitCode() int { return int(e) }
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, x.ExitOK},
+		{flag.ErrHelp, x.ExitOK},
+		{errors.New("boom"), x.ExitFailure},
+		{fmt.Errorf("wrapped: %w", exitError(42)), 42},
+		{&x.UsageError{Err: errors.New("bad flag")}, x.ExitUsage},
+		{&x.UnknownCommandError{Path: "tool"}, x.ExitUsage},
+		{&x.UnknownCommandError{Path: "tool", Name: "nope"}, x.ExitNotFound},
+		{context.Canceled, x.ExitInterrupted},
+		{fmt.Errorf("%w: %w", context.Canceled, &x.SignalError{Signal: syscall.SIGTERM}), 128 + 15},
+	}
+	for _, tt := range tests {
+		if got := x.ExitCode(tt.err); got != tt.want {
+			t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestMain_Exit(t *testing.T) {
+	reg := x.NewRegistry("tool")
+	reg.Handle("ok", func([]string) x.Command {
+		return func(ctx context.Context) error {
+			if a, ok := app.FromContext(ctx); !ok || a.Name != "tool" {
+				return errors.New("no app in context")
+			}
+			return nil
+		}
+	})
+	reg.Handle("flags", func(args []string) x.Command {
+		return func(context.Context) error {
+			return x.FlagsParse(args)
+		}
+	})
+	tests := []struct {
+		args []string
+		want int
+		out  string
+	}{
+		{[]string{"ok"}, x.ExitOK, ""},
+		{nil, x.ExitUsage, "error: no command given\n"},
+		{[]string{"nope"}, x.ExitNotFound, "error: tool: unknown command"},
+		{[]string{"flags", "-x"}, x.ExitUsage, "error: flag provided but not defined: -x\n"},
+	}
+	for _, tt := range tests {
+		var stderr bytes.Buffer
+		code := -1
+		x.Main(reg.Select,
+			x.MainArgs(tt.args),
+			x.MainApp(app.OverrideName("tool")),
+			x.MainContext(x.WithStderr(t.Context(), &stderr)),
+			x.MainExit(func(c int) { code = c }),
+		)
+		if code != tt.want {
+			t.Errorf("%q: exit code = %d, want %d", tt.args, code, tt.want)
+		}
+		if !strings.HasPrefix(stderr.String(), tt.out) || (tt.out == "") != (stderr.Len() == 0) {
+			t.Errorf("%q: stderr = %q, want %q", tt.args, stderr.String(), tt.out)
+		}
+	}
+}
+
+func TestMain_Signal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs SIGTERM")
+	}
+	cs := func(string, []string) x.Command {
+		return func(ctx context.Context) error {
+			_ = syscall.Kill(os.Getpid(), syscall.SIGTERM)
+			<-ctx.Done()
+			return ctx.Err()
+		}
+	}
+	var stderr bytes.Buffer
+	code := -1
+	x.Main(cs,
+		x.MainArgs([]string{"wait"}),
+		x.MainApp(app.OverrideName("tool")),
+		x.MainContext(x.WithStderr(t.Context(), &stderr)),
+		x.MainExit(func(c int) { code = c }),
+	)
+	if code != 128+int(syscall.SIGTERM) {
+		t.Errorf("exit code = %d, want %d", code, 128+int(syscall.SIGTERM))
+	}
+	if want := "error: context canceled: received terminated\n"; stderr.String() != want {
+		t.Errorf("stderr = %q, want %q", stderr.String(), want)
+	}
+}
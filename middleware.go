@@ -0,0 +1,137 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"charm.land/log/v2"
+)
+
+// Middleware wraps a Command with behaviour shared by many commands.
+type Middleware func(Command) Command
+
+// Chain combines the middlewares into one. The first middleware is the outermost.
+func Chain(ms ...Middleware) Middleware {
+	return func(cmd Command) Command {
+		for i := len(ms) - 1; i >= 0; i-- {
+			cmd = ms[i](cmd)
+		}
+		return cmd
+	}
+}
+
+// WrapSelector returns a CommandSelector wrapping every Command returned by cs with the middlewares.
+// Passing the result to [Batch] or [BatchOnce] wraps the commands of batches too.
+// The name and arguments of the command are available to the middlewares through [InvocationFrom].
+func WrapSelector(cs CommandSelector, ms ...Middleware) CommandSelector {
+	mw := Chain(ms...)
+	return func(name string, args []string) Command {
+		cmd := mw(selectCommand(cs, name, args))
+		inv := Invocation{Name: name, Args: args}
+		return func(ctx context.Context) error {
+			return cmd(context.WithValue(ctx, invocationKey{}, inv))
+		}
+	}
+}
+
+// Invocation is the name and the arguments a command was selected with.
+type Invocation struct {
+	Name string
+	Args []string
+}
+
+type invocationKey struct{}
+
+// InvocationFrom returns the invocation of the command run with ctx, see [WrapSelector].
+func InvocationFrom(ctx context.Context) (Invocation, bool) {
+	inv, ok := ctx.Value(invocationKey{}).(Invocation)
+	return inv, ok
+}
+
+// Logging logs the start and the end of every command. A nil logger means the default charm logger.
+func Logging(logger *log.Logger) Middleware {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return func(cmd Command) Command {
+		return func(ctx context.Context) error {
+			inv, _ := InvocationFrom(ctx)
+			logger.Info("command started", "name", inv.Name, "args", inv.Args)
+			start := time.Now()
+			err := cmd(ctx)
+			if err != nil {
+				logger.Error("command failed", "name", inv.Name, "duration", time.Since(start), "err", err)
+			} else {
+				logger.Info("command finished", "name", inv.Name, "duration", time.Since(start))
+			}
+			return err
+		}
+	}
+}
+
+// Timing calls record with the duration and the result of every command.
+func Timing(record func(inv Invocation, d time.Duration, err error)) Middleware {
+	return func(cmd Command) Command {
+		return func(ctx context.Context) error {
+			start := time.Now()
+			err := cmd(ctx)
+			inv, _ := InvocationFrom(ctx)
+			record(inv, time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// PanicError is returned by commands wrapped with [Recovery] when they panic.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n\n%s", e.Value, e.Stack)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Recovery converts panics of commands into a [*PanicError] carrying the stack trace.
+func Recovery() Middleware {
+	return func(cmd Command) Command {
+		return func(ctx context.Context) (err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					err = &PanicError{Value: v, Stack: debug.Stack()}
+				}
+			}()
+			return cmd(ctx)
+		}
+	}
+}
+
+// CancelCause adds the cause of the cancellation to errors of commands whose context was cancelled,
+// so that "context canceled" tells why.
+func CancelCause() Middleware {
+	return func(cmd Command) Command {
+		return func(ctx context.Context) error {
+			err := cmd(ctx)
+			if err == nil || ctx.Err() == nil {
+				return err
+			}
+			cause := context.Cause(ctx)
+			if errors.Is(err, cause) {
+				return err
+			}
+			return fmt.Errorf("%w: %w", err, cause)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teghnet/x"
+)
+
+func TestChain_Order(t *testing.T) {
+	var calls []string
+	mw := func(name string) x.Middleware {
+		return func(cmd x.Command) x.Command {
+			return func(ctx context.Context) error {
+				calls = append(calls, name)
+				return cmd(ctx)
+			}
+		}
+	}
+	cmd := x.Chain(mw("outer"), mw("inner"))(func(context.Context) error {
+		calls = append(calls, "cmd")
+		return nil
+	})
+	if err := cmd(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"outer", "inner", "cmd"}; !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
+
+func TestWrapSelector_Batch(t *testing.T) {
+	cs := func(name string, args []string) x.Command {
+		return func(context.Context) error {
+			if name == "panic" {
+				panic("oops")
+			}
+			return nil
+		}
+	}
+	var timed []string
+	wrapped := x.WrapSelector(cs,
+		x.Timing(func(inv x.Invocation, d time.Duration, err error) {
+			timed = append(timed, inv.Name)
+		}),
+		x.Recovery(),
+	)
+	in := writeBatch(t, "b.jsonl", `["ok"] ["panic"]`)
+	err := x.BatchOnce([]string{"batch", "-i", in}, wrapped)(t.Context())
+	var pe *x.PanicError
+	if !errors.As(err, &pe) || pe.Value != "oops" || !strings.Contains(string(pe.Stack), "middleware_test.go") {
+		t.Errorf("Batch() error = %v, want PanicError with stack", err)
+	}
+	if want := []string{"ok", "panic"}; !slices.Equal(timed, want) {
+		t.Errorf("timed = %q, want %q", timed, want)
+	}
+}
+
+func TestCancelCause(t *testing.T) {
+	errShutdown := errors.New("shutting down")
+	ctx, cancel := context.WithCancelCause(t.Context())
+	cancel(errShutdown)
+	err := x.CancelCause()(func(ctx context.Context) error { return ctx.Err() })(ctx)
+	if !errors.Is(err, errShutdown) || !errors.Is(err, context.Canceled) {
+		t.Errorf("CancelCause() error = %v, want both context.Canceled and the cause", err)
+	}
+}
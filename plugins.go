@@ -0,0 +1,132 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/teghnet/x/app"
+	"github.com/teghnet/x/paths"
+)
+
+// PluginOption customizes the plugin lookup of [Plugins].
+type PluginOption func(*pluginConfig)
+
+// PluginPrefix sets the prefix of plugin executables. By default, it is the name of the [app.App].
+func PluginPrefix(prefix string) PluginOption {
+	return func(c *pluginConfig) { c.prefix = prefix }
+}
+
+// PluginXDG sets the app directories. By default, those of the [app.App] the context was derived from are used.
+func PluginXDG(xdg paths.XDG) PluginOption {
+	return func(c *pluginConfig) { c.xdg = xdg }
+}
+
+type pluginConfig struct {
+	prefix string
+	xdg    paths.XDG
+}
+
+// Plugins returns a CommandSelector which, for commands unknown to cs, runs an external executable
+// named <prefix>-<command>, the way git runs git-<command>.
+//
+// The executable is looked up in the "plugins" directory under the DataPath and then on $PATH.
+// It gets the remaining arguments, the standard input and the outputs of the command,
+// and the app directories in <PREFIX>_CONFIG_DIR, <PREFIX>_DATA_DIR, <PREFIX>_CACHE_DIR and <PREFIX>_STATE_DIR.
+// When the plugin exits with a non-zero status, the returned error is an [*exec.ExitError] carrying the code.
+func Plugins(cs CommandSelector, opts ...PluginOption) CommandSelector {
+	var conf pluginConfig
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	return func(name string, args []string) Command {
+		cmd := cs(name, args)
+		return func(ctx context.Context) error {
+			err := error(&UnknownCommandError{Name: name})
+			if cmd != nil {
+				err = cmd(ctx)
+				var uce *UnknownCommandError
+				if !errors.As(err, &uce) || uce.Name != name || strings.Contains(uce.Path, " ") {
+					return err
+				}
+			}
+			c := conf
+			if a, ok := app.FromContext(ctx); ok && c.prefix == "" {
+				c.prefix = a.Name
+			}
+			c.xdg, _ = appXDG(ctx, c.xdg)
+			path, ok := c.lookup(name)
+			if !ok {
+				return err
+			}
+			return c.run(ctx, path, args)
+		}
+	}
+}
+
+var pluginName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// lookup returns the path of the plugin executable for the command.
+func (c pluginConfig) lookup(name string) (string, bool) {
+	if c.prefix == "" || !pluginName.MatchString(name) {
+		return "", false
+	}
+	exe := c.prefix + "-" + name
+	if c.xdg != nil {
+		p := c.xdg.DataPath("plugins", exe)
+		if info, err := os.Stat(p); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+			return p, true
+		}
+	}
+	p, err := exec.LookPath(exe)
+	return p, err == nil
+}
+
+// pluginWaitDelay is how long a plugin may take to exit after being interrupted.
+const pluginWaitDelay = 5 * time.Second
+
+func (c pluginConfig) run(ctx context.Context, path string, args []string) error {
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = Stdout(ctx)
+	cmd.Stderr = Stderr(ctx)
+	cmd.Env = append(os.Environ(), c.env()...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = pluginWaitDelay
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("plugin %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// env returns the variables exporting the app directories to the plugin.
+func (c pluginConfig) env() []string {
+	if c.xdg == nil {
+		return nil
+	}
+	prefix := envPrefix(c.prefix)
+	return []string{
+		prefix + "CONFIG_DIR=" + c.xdg.ConfigPath(),
+		prefix + "DATA_DIR=" + c.xdg.DataPath(),
+		prefix + "CACHE_DIR=" + c.xdg.CachePath(),
+		prefix + "STATE_DIR=" + c.xdg.StatePath(),
+	}
+}
+
+var nonWord = regexp.MustCompile(`\W`)
+
+// envPrefix returns the prefix of environment variables of the app: "my-tool" becomes "MY_TOOL_".
+func envPrefix(name string) string {
+	return strings.ToUpper(nonWord.ReplaceAllString(name, "_")) + "_"
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/teghnet/x"
+)
+
+func TestPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin scripts need a POSIX shell")
+	}
+	xdg := testXDG(t.TempDir())
+	dir := xdg.DataPath("plugins")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\necho \"$@\" \"$MY_TOOL_STATE_DIR\"\nexit ${CODE:-0}\n"
+	if err := os.WriteFile(filepath.Join(dir, "my-tool-hello"), []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	reg := x.NewRegistry("my-tool")
+	reg.Handle("known", func([]string) x.Command {
+		return func(context.Context) error { return nil }
+	})
+	cs := x.Plugins(reg.Select, x.PluginPrefix("my-tool"), x.PluginXDG(xdg))
+
+	var out bytes.Buffer
+	ctx := x.WithStdout(t.Context(), &out)
+	if err := cs("hello", []string{"a", "b"})(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(out.String()), "a b "+xdg.StatePath(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	t.Setenv("CODE", "3")
+	var exitErr *exec.ExitError
+	if err := cs("hello", nil)(ctx); !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("err = %v, want exit code 3", err)
+	}
+
+	if err := cs("missing", nil)(ctx); !errors.Is(err, x.ErrCommandNotFound) {
+		t.Errorf("err = %v, want ErrCommandNotFound", err)
+	}
+	if err := cs("known", nil)(ctx); err != nil {
+		t.Errorf("err = %v, want nil", err)
+	}
+}
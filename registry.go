@@ -0,0 +1,368 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// Handler builds a Command from the arguments that follow the command name.
+type Handler func(args []string) Command
+
+// Registry is a hierarchical set of named commands.
+//
+// Its Select method is a [CommandSelector], so a Registry plugs into [Batch] and [BatchOnce]:
+//
+//	reg := x.NewRegistry("tool")
+//	reg.Handle("convert", convert, x.CommandShort("convert files"))
+//	reg.Group("db").Handle("migrate", migrate)
+//	err := x.BatchOnce(os.Args[1:], reg.Select)(ctx)
+type Registry struct {
+	path    string
+	entries []*Entry
+}
+
+var _ CommandSelector = (*Registry)(nil).Select
+
+// Entry describes a registered command.
+type Entry struct {
+	Name    string
+	Aliases []string
+	Short   string
+	Long    string
+	Handler Handler
+	// Flags describes the flags of the command for help and shell completion, see [CommandFlags].
+	Flags func() []FlagOption
+
+	sub *Registry
+}
+
+// Sub returns the nested Registry of a group or nil for a plain command.
+func (e *Entry) Sub() *Registry {
+	return e.sub
+}
+
+// CommandOption is a function type used to customize an Entry during registration.
+type CommandOption func(*Entry)
+
+// CommandAliases adds alternative names for the command.
+func CommandAliases(aliases ...string) CommandOption {
+	return func(e *Entry) {
+		e.Aliases = append(e.Aliases, aliases...)
+	}
+}
+
+// CommandShort sets the one-line description shown in command listings.
+func CommandShort(short string) CommandOption {
+	return func(e *Entry) {
+		e.Short = short
+	}
+}
+
+// CommandLong sets the detailed description shown by `help <command>`.
+func CommandLong(long string) CommandOption {
+	return func(e *Entry) {
+		e.Long = long
+	}
+}
+
+// CommandFlags describes the flags accepted by the command, for help and shell completion.
+// The function is called for every description, so it should bind the flags to fresh variables.
+// Parse the arguments of the handler with the same declaration, so the two cannot drift apart,
+// like [Batch] does with [BatchFlags]:
+//
+//	func convertFlags(in *string) []x.FlagOption {
+//		return []x.FlagOption{x.Flag(in, "i", "input file"), x.FlagFile("i")}
+//	}
+//
+//	reg.Handle("convert", func(args []string) x.Command {
+//		var in string
+//		err := x.FlagsParse(args, convertFlags(&in)...)
+//		...
+//	}, x.CommandFlags(func() []x.FlagOption { return convertFlags(new(string)) }))
+func CommandFlags(fn func() []FlagOption) CommandOption {
+	return func(e *Entry) {
+		e.Flags = fn
+	}
+}
+
+// FlagSet returns the flags described with [CommandFlags] or nil.
+func (e *Entry) FlagSet() *flag.FlagSet {
+	if e.Flags == nil {
+		return nil
+	}
+	return flagSet(e.Flags()...)
+}
+
+// NewRegistry returns an empty Registry. The name is used as the root of command paths in help and errors.
+func NewRegistry(name string) *Registry {
+	return &Registry{path: name}
+}
+
+// Handle registers a command. It panics if the name or one of its aliases is already taken.
+func (r *Registry) Handle(name string, h Handler, opts ...CommandOption) *Entry {
+	return r.register(&Entry{Name: name, Handler: h}, opts)
+}
+
+// Group registers a command with nested subcommands and returns the nested Registry.
+// Calling Group again with the same name returns the existing Registry.
+func (r *Registry) Group(name string, opts ...CommandOption) *Registry {
+	if e := r.Lookup(name); e != nil && e.sub != nil {
+		for _, opt := range opts {
+			opt(e)
+		}
+		return e.sub
+	}
+	e := r.register(&Entry{Name: name, sub: &Registry{path: r.path + " " + name}}, opts)
+	return e.sub
+}
+
+func (r *Registry) register(e *Entry, opts []CommandOption) *Entry {
+	for _, opt := range opts {
+		opt(e)
+	}
+	for _, n := range append([]string{e.Name}, e.Aliases...) {
+		if n == "" {
+			panic(fmt.Sprintf("x: empty command name in %q", r.path))
+		}
+		if r.Lookup(n) != nil {
+			panic(fmt.Sprintf("x: command %q already registered in %q", n, r.path))
+		}
+	}
+	r.entries = append(r.entries, e)
+	return e
+}
+
+// Lookup returns the entry registered under the name or alias, or nil.
+func (r *Registry) Lookup(name string) *Entry {
+	for _, e := range r.entries {
+		if e.Name == name || slices.Contains(e.Aliases, name) {
+			return e
+		}
+	}
+	return nil
+}
+
+// flagSetOf returns the flags of the command Select runs for the name and arguments,
+// as described with [CommandFlags], or nil if they are not known.
+func (r *Registry) flagSetOf(name string, args []string) *flag.FlagSet {
+	e := r.Lookup(name)
+	switch {
+	case e == nil:
+		return nil
+	case e.sub != nil && len(args) > 0:
+		return e.sub.flagSetOf(args[0], args[1:])
+	}
+	return e.FlagSet()
+}
+
+// Entries returns the registered entries in registration order.
+func (r *Registry) Entries() []*Entry {
+	return slices.Clone(r.entries)
+}
+
+// Select implements [CommandSelector].
+// Groups dispatch on the first argument; "help" prints usage unless a command of that name is registered.
+// Unknown names yield a Command returning an [*UnknownCommandError].
+func (r *Registry) Select(name string, args []string) Command {
+	e := r.Lookup(name)
+	if e == nil {
+		if name == "help" {
+			return r.help(args)
+		}
+		return failed(r.unknown(name))
+	}
+	if e.sub == nil {
+		return e.Handler(args)
+	}
+	if len(args) == 0 {
+		return failed(e.sub.unknown(""))
+	}
+	return e.sub.Select(args[0], args[1:])
+}
+
+func (r *Registry) unknown(name string) error {
+	names := make([]string, 0, len(r.entries))
+	for _, e := range r.entries {
+		names = append(names, e.Name)
+	}
+	err := &UnknownCommandError{Path: r.path, Name: name}
+	if name == "" {
+		err.Suggestions = names
+		return err
+	}
+	for _, e := range r.entries {
+		if suggest(name, e.Name, e.Aliases...) {
+			err.Suggestions = append(err.Suggestions, e.Name)
+		}
+	}
+	return err
+}
+
+// help returns a Command printing the usage of the registry or of the command named by args.
+func (r *Registry) help(args []string) Command {
+	return func(ctx context.Context) error {
+		reg, e := r, (*Entry)(nil)
+		for i, a := range args {
+			e = reg.Lookup(a)
+			if e == nil {
+				return reg.unknown(a)
+			}
+			if e.sub == nil {
+				if i != len(args)-1 {
+					return fmt.Errorf("%s %s: not a command group", reg.path, e.Name)
+				}
+				break
+			}
+			reg = e.sub
+		}
+		if e != nil && e.sub == nil {
+			return e.Usage(Stdout(ctx), reg.path)
+		}
+		if e != nil && e.Long != "" {
+			_, _ = fmt.Fprintf(Stdout(ctx), "%s\n\n", e.Long)
+		}
+		return reg.Usage(Stdout(ctx))
+	}
+}
+
+// Usage writes the list of commands in the registry.
+func (r *Registry) Usage(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "Usage: %s <command> [arguments]\n\nCommands:\n", r.path); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, e := range r.entries {
+		short := e.Short
+		if len(e.Aliases) > 0 {
+			short = strings.TrimSpace(fmt.Sprintf("%s (aliases: %s)", short, strings.Join(e.Aliases, ", ")))
+		}
+		if _, err := fmt.Fprintf(tw, "  %s\t%s\n", e.Name, short); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+// Usage writes the description of the command; path is the path of its parent registry.
+func (e *Entry) Usage(w io.Writer, path string) error {
+	fs := e.FlagSet()
+	args := "[arguments]"
+	if s := argsSynopsis(fs); s != "" {
+		args = "[flags] " + s
+	}
+	_, err := fmt.Fprintf(w, "Usage: %s %s %s\n", path, e.Name, args)
+	if err != nil {
+		return err
+	}
+	if len(e.Aliases) > 0 {
+		if _, err = fmt.Fprintf(w, "Aliases: %s\n", strings.Join(e.Aliases, ", ")); err != nil {
+			return err
+		}
+	}
+	desc := e.Long
+	if desc == "" {
+		desc = e.Short
+	}
+	if desc != "" {
+		if _, err = fmt.Fprintf(w, "\n%s\n", desc); err != nil {
+			return err
+		}
+	}
+	if fs != nil {
+		if _, err = fmt.Fprintf(w, "\nFlags:\n"); err != nil {
+			return err
+		}
+		fs.SetOutput(w)
+		printFlags(fs)
+		if argsSynopsis(fs) != "" {
+			if _, err = fmt.Fprintf(w, "\nArguments:\n"); err != nil {
+				return err
+			}
+			printArgs(w, fs)
+		}
+	}
+	return nil
+}
+
+// ErrCommandNotFound is matched by errors.Is for every [*UnknownCommandError].
+var ErrCommandNotFound = errors.New("command not found")
+
+// UnknownCommandError is returned when a [Registry] has no command of the requested name.
+// An empty Name means a group was called without a subcommand; Suggestions then lists all of them.
+type UnknownCommandError struct {
+	Path        string
+	Name        string
+	Suggestions []string
+}
+
+func (e *UnknownCommandError) Error() string {
+	prefix := ""
+	if e.Path != "" {
+		prefix = e.Path + ": "
+	}
+	if e.Name == "" {
+		return fmt.Sprintf("%smissing command, available: %s", prefix, strings.Join(e.Suggestions, ", "))
+	}
+	msg := fmt.Sprintf("%sunknown command %q", prefix, e.Name)
+	switch len(e.Suggestions) {
+	case 0:
+		return msg
+	case 1:
+		return fmt.Sprintf("%s, did you mean %q?", msg, e.Suggestions[0])
+	default:
+		return fmt.Sprintf("%s, did you mean one of: %s?", msg, strings.Join(e.Suggestions, ", "))
+	}
+}
+
+func (e *UnknownCommandError) Unwrap() error {
+	return ErrCommandNotFound
+}
+
+// failed returns a Command that returns err.
+func failed(err error) Command {
+	return func(context.Context) error { return err }
+}
+
+// suggest reports whether the typed name is close to the command name or one of its aliases.
+func suggest(typed, name string, aliases ...string) bool {
+	for _, n := range append([]string{name}, aliases...) {
+		if strings.HasPrefix(n, typed) || strings.HasPrefix(typed, n) {
+			return true
+		}
+		if levenshtein(typed, n) <= max(1, len(n)/3) {
+			return true
+		}
+	}
+	return false
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/teghnet/x"
+)
+
+func TestRegistry_Select(t *testing.T) {
+	var got []string
+	record := func(name string) x.Handler {
+		return func(args []string) x.Command {
+			return func(context.Context) error {
+				got = append([]string{name}, args...)
+				return nil
+			}
+		}
+	}
+	reg := x.NewRegistry("tool")
+	reg.Handle("convert", record("convert"), x.CommandAliases("conv"))
+	reg.Group("db").Group("migrate").Handle("up", record("up"))
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"convert", "-i", "a"}, []string{"convert", "-i", "a"}},
+		{[]string{"conv"}, []string{"convert"}},
+		{[]string{"db", "migrate", "up", "3"}, []string{"up", "3"}},
+	}
+	for _, tt := range tests {
+		got = nil
+		if err := x.BatchOnce(tt.args, reg.Select)(t.Context()); err != nil {
+			t.Fatalf("BatchOnce(%q) error = %v", tt.args, err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("BatchOnce(%q) ran %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRegistry_Unknown(t *testing.T) {
+	noop := func([]string) x.Command { return func(context.Context) error { return nil } }
+	reg := x.NewRegistry("tool")
+	reg.Handle("convert", noop)
+	reg.Handle("config", noop)
+	reg.Group("db").Handle("migrate", noop)
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"covnert"}, []string{"convert"}},
+		{[]string{"con"}, []string{"convert", "config"}},
+		{[]string{"zzz"}, nil},
+		{[]string{"db", "mirgate"}, []string{"migrate"}},
+		{[]string{"db"}, []string{"migrate"}},
+	}
+	for _, tt := range tests {
+		err := x.BatchOnce(tt.args, reg.Select)(t.Context())
+		if !errors.Is(err, x.ErrCommandNotFound) {
+			t.Fatalf("BatchOnce(%q) error = %v, want ErrCommandNotFound", tt.args, err)
+		}
+		var uce *x.UnknownCommandError
+		if !errors.As(err, &uce) {
+			t.Fatalf("BatchOnce(%q) error = %T, want *UnknownCommandError", tt.args, err)
+		}
+		if !slices.Equal(uce.Suggestions, tt.want) {
+			t.Errorf("BatchOnce(%q) suggestions = %q, want %q", tt.args, uce.Suggestions, tt.want)
+		}
+	}
+}
+
+func TestRegistry_DuplicatePanics(t *testing.T) {
+	noop := func([]string) x.Command { return nil }
+	reg := x.NewRegistry("tool")
+	reg.Handle("convert", noop, x.CommandAliases("c"))
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+	reg.Handle("copy", noop, x.CommandAliases("c"))
+}
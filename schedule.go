@@ -0,0 +1,339 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"charm.land/log/v2"
+	"github.com/teghnet/x/paths"
+)
+
+// Clock tells the time and waits. [Schedule] takes it as an option, so tests can control the time.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the Clock of the operating system.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time                         { return time.Now() }
+func (SystemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// ScheduleOption sets a default of [Schedule]. Command-line flags given to the schedule take precedence.
+type ScheduleOption func(*scheduleConfig)
+
+// ScheduleFile sets the schedule file (flag -f). By default, it is "schedule.json" under the ConfigPath.
+func ScheduleFile(name string) ScheduleOption {
+	return func(c *scheduleConfig) { c.file = name }
+}
+
+// ScheduleXDG sets the directories of the schedule file and of the state ("schedule.json" under the StatePath).
+// By default, those of the [app.App] the context was derived from are used.
+func ScheduleXDG(xdg paths.XDG) ScheduleOption {
+	return func(c *scheduleConfig) { c.xdg = xdg }
+}
+
+// ScheduleClock sets the clock. By default, it is the [SystemClock].
+func ScheduleClock(clock Clock) ScheduleOption {
+	return func(c *scheduleConfig) { c.clock = clock }
+}
+
+type scheduleConfig struct {
+	file  string
+	list  bool
+	xdg   paths.XDG
+	clock Clock
+}
+
+// Missed run policies of scheduled jobs.
+const (
+	MissedSkip    = "skip"
+	MissedCatchup = "catchup"
+)
+
+// scheduleJob is an entry of the schedule file.
+type scheduleJob struct {
+	ID      string       `json:"id"`
+	Cron    string       `json:"cron,omitempty"`
+	Every   jsonDuration `json:"every,omitempty"`
+	Args    []string     `json:"args"`
+	Missed  string       `json:"missed,omitempty"`
+	Timeout jsonDuration `json:"timeout,omitempty"`
+
+	cron    *Cron
+	next    time.Time
+	running bool
+}
+
+// after returns the first run of the job after t.
+func (j *scheduleJob) after(t time.Time) time.Time {
+	if j.cron != nil {
+		return j.cron.Next(t)
+	}
+	return t.Add(time.Duration(j.Every))
+}
+
+// jobState is what is remembered about a job between runs of the scheduler.
+type jobState struct {
+	LastRun    time.Time `json:"last_run"`
+	LastStatus string    `json:"last_status,omitempty"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// Schedule returns a Command running the commands of a schedule file when they are due, until its context is cancelled.
+// The file is a JSON array of jobs:
+//
+//	[
+//	  {"id": "backup", "cron": "30 2 * * *", "args": ["backup", "-full"], "missed": "catchup"},
+//	  {"id": "sync", "every": "15m", "args": ["batch", "-i", "sync.jsonl"], "timeout": "10m"}
+//	]
+//
+// A job runs at the times of a cron expression (see [ParseCron]) or at a fixed interval. A job still running
+// when it is due again is not started twice; that run is skipped. The time of the last run of every job
+// is kept in "schedule.json" under the StatePath. Runs missed while the scheduler was not running are skipped
+// or, with "missed": "catchup", made up for with a single run at the start.
+//
+// With the flag -list, the jobs and the times of their next runs are printed instead.
+func Schedule(args []string, cs CommandSelector, opts ...ScheduleOption) Command {
+	return func(ctx context.Context) error {
+		conf := scheduleConfig{clock: SystemClock{}}
+		for _, opt := range opts {
+			opt(&conf)
+		}
+		err := FlagsParse(args,
+			Flag(&conf.file, "f", "schedule file (default: schedule.json under the app ConfigPath)"),
+			FlagFile("f"),
+			Flag(&conf.list, "list", "print the jobs and their next runs"),
+		)
+		if err != nil {
+			return err
+		}
+		xdg, ok := appXDG(ctx, conf.xdg)
+		if !ok {
+			return errors.New("schedule: no app in the context")
+		}
+		if conf.file == "" {
+			conf.file = xdg.ConfigPath("schedule.json")
+		}
+		jobs, err := readSchedule(conf.file)
+		if err != nil {
+			return err
+		}
+		s := &scheduler{conf: conf, cs: cs, jobs: jobs, stateFile: xdg.StatePath("schedule.json")}
+		if err := s.load(); err != nil {
+			return err
+		}
+		if conf.list {
+			return s.print(Stdout(ctx))
+		}
+		return s.run(ctx)
+	}
+}
+
+func readSchedule(name string) ([]*scheduleJob, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*scheduleJob
+	if err := json.Unmarshal(b, &jobs); err != nil {
+		return nil, fmt.Errorf("schedule %s: %w", name, err)
+	}
+	ids := make(map[string]bool)
+	for i, j := range jobs {
+		switch {
+		case j.ID == "":
+			return nil, fmt.Errorf("schedule %s: job #%d has no id", name, i)
+		case ids[j.ID]:
+			return nil, fmt.Errorf("schedule %s: duplicate id %q", name, j.ID)
+		case len(j.Args) == 0:
+			return nil, fmt.Errorf("schedule %s: job %s has no args", name, j.ID)
+		case (j.Cron == "") == (j.Every == 0):
+			return nil, fmt.Errorf("schedule %s: job %s needs either cron or every", name, j.ID)
+		case j.Every < 0:
+			return nil, fmt.Errorf("schedule %s: job %s has a negative interval", name, j.ID)
+		}
+		switch j.Missed {
+		case "":
+			j.Missed = MissedSkip
+		case MissedSkip, MissedCatchup:
+		default:
+			return nil, fmt.Errorf("schedule %s: job %s: missed must be %q or %q", name, j.ID, MissedSkip, MissedCatchup)
+		}
+		if j.Cron != "" {
+			if j.cron, err = ParseCron(j.Cron); err != nil {
+				return nil, fmt.Errorf("schedule %s: job %s: %w", name, j.ID, err)
+			}
+		}
+		ids[j.ID] = true
+	}
+	return jobs, nil
+}
+
+type scheduler struct {
+	conf      scheduleConfig
+	cs        CommandSelector
+	jobs      []*scheduleJob
+	stateFile string
+	state     map[string]*jobState
+}
+
+// scheduleRun is the result of a run of a job.
+type scheduleRun struct {
+	job *scheduleJob
+	err error
+}
+
+// load reads the state and plans the first run of every job.
+func (s *scheduler) load() error {
+	s.state = make(map[string]*jobState)
+	b, err := os.ReadFile(s.stateFile)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(b, &s.state); err != nil {
+			return fmt.Errorf("schedule state %s: %w", s.stateFile, err)
+		}
+	}
+	now := s.conf.clock.Now()
+	for _, j := range s.jobs {
+		j.next = j.after(now)
+		st, ok := s.state[j.ID]
+		if !ok || st.LastRun.IsZero() {
+			continue
+		}
+		switch due := j.after(st.LastRun.In(now.Location())); {
+		case due.IsZero():
+		case due.After(now):
+			j.next = due // keeps the cadence of intervals
+		default:
+			log.Info("schedule: missed run", "job", j.ID, "at", due, "policy", j.Missed)
+			if j.Missed == MissedCatchup {
+				j.next = now
+			}
+		}
+	}
+	return nil
+}
+
+func (s *scheduler) run(ctx context.Context) error {
+	done := make(chan scheduleRun)
+	running := 0
+	for {
+		now := s.conf.clock.Now()
+		var next time.Time
+		for _, j := range s.jobs {
+			for !j.next.IsZero() && !j.next.After(now) {
+				if j.running {
+					log.Warn("schedule: skipping run, previous one still running", "job", j.ID, "at", j.next)
+				} else {
+					s.start(ctx, j, done)
+					running++
+				}
+				j.next = j.after(now)
+			}
+			if !j.next.IsZero() && (next.IsZero() || j.next.Before(next)) {
+				next = j.next
+			}
+		}
+		var wait <-chan time.Time
+		if !next.IsZero() {
+			wait = s.conf.clock.After(next.Sub(now))
+		}
+		select {
+		case <-ctx.Done():
+			for ; running > 0; running-- {
+				s.finish(<-done)
+			}
+			return context.Cause(ctx)
+		case <-wait:
+		case r := <-done:
+			running--
+			s.finish(r)
+		}
+	}
+}
+
+// start runs the job in the background and records the start in the state.
+func (s *scheduler) start(ctx context.Context, j *scheduleJob, done chan<- scheduleRun) {
+	j.running = true
+	s.state[j.ID] = &jobState{LastRun: j.next, LastStatus: "running"}
+	s.save()
+	log.Info("schedule: running job", "job", j.ID, "args", j.Args)
+	go func() {
+		err := attempt(ctx, BatchOnce(j.Args, s.cs), time.Duration(j.Timeout))
+		done <- scheduleRun{job: j, err: err}
+	}()
+}
+
+// finish records the result of a run in the state.
+func (s *scheduler) finish(r scheduleRun) {
+	r.job.running = false
+	st := s.state[r.job.ID]
+	st.LastStatus, st.LastError = batchOK, ""
+	if r.err != nil {
+		st.LastStatus, st.LastError = batchFailed, r.err.Error()
+		log.Error("schedule: job failed", "job", r.job.ID, "err", r.err)
+	}
+	s.save()
+}
+
+// save writes the state, replacing the file at once so it is never left half-written.
+func (s *scheduler) save() {
+	err := func() error {
+		if err := os.MkdirAll(filepath.Dir(s.stateFile), 0700); err != nil {
+			return err
+		}
+		b, err := json.MarshalIndent(s.state, "", "  ")
+		if err != nil {
+			return err
+		}
+		tmp := s.stateFile + ".tmp"
+		if err := os.WriteFile(tmp, b, 0600); err != nil {
+			return err
+		}
+		return os.Rename(tmp, s.stateFile)
+	}()
+	if err != nil {
+		log.Warn("schedule: could not save the state", "file", s.stateFile, "err", err)
+	}
+}
+
+// print lists the jobs with their last and next runs.
+func (s *scheduler) print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "ID\tWHEN\tLAST RUN\tSTATUS\tNEXT RUN\tCOMMAND"); err != nil {
+		return err
+	}
+	for _, j := range s.jobs {
+		when := j.Cron
+		if when == "" {
+			when = "every " + time.Duration(j.Every).String()
+		}
+		last, status := "-", "-"
+		if st, ok := s.state[j.ID]; ok {
+			last, status = st.LastRun.Format(time.DateTime), st.LastStatus
+		}
+		next := "never"
+		if !j.next.IsZero() {
+			next = j.next.Format(time.DateTime)
+		}
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", j.ID, when, last, status, next, ShellJoin(j.Args...)); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
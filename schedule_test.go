@@ -0,0 +1,217 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teghnet/x"
+)
+
+func TestParseCron(t *testing.T) {
+	from := time.Date(2026, time.January, 31, 10, 17, 30, 0, time.UTC) // a Saturday
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 1, 31, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 1, 31, 10, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2026, 1, 31, 13, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 2 * * mon-fri", time.Date(2026, 2, 2, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2026, 2, 6, 0, 0, 0, 0, time.UTC)}, // day of month or day of week
+		{"0 0 29 feb *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		c, err := x.ParseCron(tt.spec)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := c.Next(from); !got.Equal(tt.want) {
+			t.Errorf("ParseCron(%q).Next = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+	for _, spec := range []string{"* * * *", "60 * * * *", "* * * foo *", "5-1 * * * *", "*/0 * * * *"} {
+		if _, err := x.ParseCron(spec); err == nil {
+			t.Errorf("ParseCron(%q): want error", spec)
+		}
+	}
+}
+
+// fakeClock is a Clock moved forward by the test.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+	} else {
+		c.waiters = append(c.waiters, fakeWaiter{c.now.Add(d), ch})
+	}
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.waiters = slices.DeleteFunc(c.waiters, func(w fakeWaiter) bool {
+		if w.at.After(c.now) {
+			return false
+		}
+		w.c <- c.now
+		return true
+	})
+}
+
+func TestSchedule(t *testing.T) {
+	xdg := testXDG(t.TempDir())
+	start := time.Date(2026, time.January, 1, 0, 5, 0, 0, time.UTC)
+	for name, content := range map[string]string{
+		xdg.ConfigPath("schedule.json"): `[
+			{"id": "tick", "every": "10m", "args": ["tick"]},
+			{"id": "nightly", "cron": "@daily", "args": ["nightly"], "missed": "catchup"},
+			{"id": "hourly", "cron": "0 * * * *", "args": ["hourly"]}
+		]`,
+		xdg.StatePath("schedule.json"): `{
+			"nightly": {"last_run": "2025-12-30T00:00:00Z"},
+			"hourly": {"last_run": "2025-12-31T00:00:00Z"}
+		}`,
+	} {
+		if err := os.MkdirAll(strings.TrimSuffix(name, "schedule.json"), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ran := make(chan string, 10)
+	release := make(chan struct{})
+	cs := func(name string, args []string) x.Command {
+		return func(ctx context.Context) error {
+			ran <- name
+			if name == "tick" {
+				<-release
+			}
+			return nil
+		}
+	}
+	expect := func(want ...string) {
+		t.Helper()
+		var got []string
+		for range want {
+			select {
+			case name := <-ran:
+				got = append(got, name)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("ran %q, want %q", got, want)
+			}
+		}
+		slices.Sort(got)
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			t.Errorf("ran %q, want %q", got, want)
+		}
+		select {
+		case name := <-ran:
+			t.Errorf("unexpected run of %s", name)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	clock := &fakeClock{now: start}
+	var list bytes.Buffer
+	err := x.Schedule([]string{"-list"}, cs, x.ScheduleXDG(xdg), x.ScheduleClock(clock))(x.WithStdout(t.Context(), &list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "hourly   0 * * * *"; !strings.Contains(list.String(), want) {
+		t.Errorf("list:\n%s\nwant %q", list.String(), want)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- x.Schedule(nil, cs, x.ScheduleXDG(xdg), x.ScheduleClock(clock))(ctx)
+	}()
+	expect("nightly") // caught up, hourly is skipped
+
+	clock.Advance(10 * time.Minute) // 00:15
+	expect("tick")
+	clock.Advance(10 * time.Minute) // 00:25, tick still running
+	expect()
+	close(release)
+	for deadline := time.Now().Add(5 * time.Second); readState(t, xdg)["tick"].LastStatus != "ok"; {
+		if time.Now().After(deadline) {
+			t.Fatal("tick did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	clock.Advance(10 * time.Minute) // 00:35
+	expect("tick")
+	clock.Advance(25 * time.Minute) // 01:00
+	expect("tick", "hourly")
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+
+	state := readState(t, xdg)
+	if got := state["hourly"]; !got.LastRun.Equal(start.Add(55*time.Minute)) || got.LastStatus != "ok" {
+		t.Errorf("hourly state = %+v", got)
+	}
+	if got := state["nightly"]; !got.LastRun.Equal(start) {
+		t.Errorf("nightly state = %+v", got)
+	}
+}
+
+type jobState struct {
+	LastRun    time.Time `json:"last_run"`
+	LastStatus string    `json:"last_status"`
+}
+
+func readState(t *testing.T, xdg testXDG) map[string]jobState {
+	t.Helper()
+	b, err := os.ReadFile(xdg.StatePath("schedule.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state map[string]jobState
+	if err := json.Unmarshal(b, &state); err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
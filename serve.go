@@ -0,0 +1,296 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"charm.land/log/v2"
+	"github.com/teghnet/x/paths"
+)
+
+// ServeOption sets a default of [Serve] and [ServeHandler]. Command-line flags given to serve take precedence.
+type ServeOption func(*serveConfig)
+
+// ServeAddr sets the address to listen on (flag -addr): "host:port" or "unix:<path>" for a unix socket.
+func ServeAddr(addr string) ServeOption {
+	return func(c *serveConfig) { c.addr = addr }
+}
+
+// ServeRegistry documents the commands of the registry in the OpenAPI document.
+func ServeRegistry(reg *Registry) ServeOption {
+	return func(c *serveConfig) { c.reg = reg }
+}
+
+// ServeState sets where the unix socket is created by default. By default, the [app.App] the context was derived from is used.
+func ServeState(xdg paths.XDG) ServeOption {
+	return func(c *serveConfig) { c.state = xdg }
+}
+
+type serveConfig struct {
+	addr  string
+	reg   *Registry
+	state paths.XDG
+}
+
+// serveFallbackAddr is listened on when there is no StatePath for the socket.
+const serveFallbackAddr = "127.0.0.1:8484"
+
+// serveShutdownTimeout is how long running requests may take to finish once the server is stopped.
+const serveShutdownTimeout = 5 * time.Second
+
+// Serve returns a Command running an HTTP server with the API of [ServeHandler] until its context is cancelled:
+//
+//	reg.Handle("serve", func(args []string) x.Command {
+//		return x.Serve(args, reg.Select, x.ServeRegistry(reg))
+//	})
+//
+// By default, it listens on the unix socket "serve.sock" under the StatePath
+// or, without an [app.App] in the context, on 127.0.0.1:8484 (flag -addr).
+func Serve(args []string, cs CommandSelector, opts ...ServeOption) Command {
+	return func(ctx context.Context) error {
+		var conf serveConfig
+		for _, opt := range opts {
+			opt(&conf)
+		}
+		err := FlagsParse(args,
+			Flag(&conf.addr, "addr", `address to listen on, "host:port" or "unix:<path>" (default: serve.sock under the app StatePath)`),
+		)
+		if err != nil {
+			return err
+		}
+		if conf.addr == "" {
+			conf.addr = serveFallbackAddr
+			if xdg, ok := appXDG(ctx, conf.state); ok {
+				conf.addr = "unix:" + xdg.StatePath("serve.sock")
+			}
+		}
+		ln, err := conf.listen()
+		if err != nil {
+			return err
+		}
+		srv := &http.Server{
+			Handler:           ServeHandler(cs, opts...),
+			BaseContext:       func(net.Listener) context.Context { return ctx },
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		done, stopped := make(chan struct{}), make(chan error, 1)
+		defer close(done)
+		go func() {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+			}
+			sctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), serveShutdownTimeout)
+			defer cancel()
+			stopped <- srv.Shutdown(sctx)
+		}()
+		log.Info("serving commands", "addr", ln.Addr().Network()+":"+ln.Addr().String())
+		if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return errors.Join(context.Cause(ctx), <-stopped)
+	}
+}
+
+// listen opens the listener, replacing a stale unix socket.
+func (c serveConfig) listen() (net.Listener, error) {
+	sock, ok := strings.CutPrefix(c.addr, "unix:")
+	if !ok {
+		return net.Listen("tcp", c.addr)
+	}
+	if err := os.MkdirAll(filepath.Dir(sock), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(sock); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(sock, 0600); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// ServeHandler returns an HTTP handler running the commands of cs:
+//
+//   - POST /commands/{name} runs the command with the arguments in the body, given like a [Batch] entry:
+//     either an array of strings or an object with "args" and an optional "timeout" (e.g. "30s").
+//     The response is a stream of JSON lines: {"stream":"stdout"|"stderr","data":"..."} for the output of the command
+//     followed by the result {"status":"ok"|"failed"|"cancelled","exit_code":0,"error":"...","duration":"..."}.
+//     Unknown commands and usage errors are answered with 404 and 400 if the command wrote nothing.
+//   - GET /openapi.json returns the OpenAPI document of the API, e.g. for generating clients with oapi-codegen.
+//
+// Commands run with the context of the request, so they are cancelled when the client goes away.
+// As any web page may send requests to localhost, commands are run only for requests with
+// the Content-Type application/json, without an Origin header and, unless they came over a unix socket,
+// with a loopback Host like localhost or 127.0.0.1, which also rules out DNS rebinding.
+func ServeHandler(cs CommandSelector, opts ...ServeOption) http.Handler {
+	var conf serveConfig
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /commands/{name}", func(w http.ResponseWriter, r *http.Request) {
+		serveCommand(w, r, cs)
+	})
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(conf.openAPI())
+	})
+	return mux
+}
+
+// serveResult is the last line of the response of a command.
+type serveResult struct {
+	Status   string `json:"status"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// serveEvent is a line of the response carrying output of a command.
+type serveEvent struct {
+	Stream string `json:"stream"`
+	Data   string `json:"data"`
+}
+
+func serveCommand(w http.ResponseWriter, r *http.Request, cs CommandSelector) {
+	if code, err := serveAllowed(r); err != nil {
+		serveError(w, code, err)
+		return
+	}
+	var e batchEntry
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		serveError(w, http.StatusBadRequest, fmt.Errorf("arguments: %w", err))
+		return
+	}
+	s := &serveStream{w: w, rc: http.NewResponseController(w)}
+	ctx := WithStderr(WithStdout(r.Context(), s.writer("stdout")), s.writer("stderr"))
+	start := time.Now()
+	err := attempt(ctx, selectCommand(cs, r.PathValue("name"), e.Args), time.Duration(e.Timeout))
+	res := serveResult{Status: batchOK, ExitCode: ExitCode(err), Duration: time.Since(start).String()}
+	switch {
+	case err == nil:
+	case r.Context().Err() != nil:
+		res.Status, res.Error = batchCancelled, err.Error()
+	default:
+		res.Status, res.Error = batchFailed, err.Error()
+	}
+	s.finish(res)
+}
+
+// serveAllowed rejects requests to run commands which a browser could have sent on behalf of a web page,
+// returning the status code to answer with.
+func serveAllowed(r *http.Request) (int, error) {
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+		return http.StatusUnsupportedMediaType, errors.New("the Content-Type must be application/json")
+	}
+	if r.Header.Get("Origin") != "" {
+		return http.StatusForbidden, errors.New("cross-origin requests are not allowed")
+	}
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok && addr.Network() == "unix" {
+		return 0, nil
+	}
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	if ip := net.ParseIP(strings.Trim(host, "[]")); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return http.StatusForbidden, fmt.Errorf("host %q is not a loopback address", r.Host)
+	}
+	return 0, nil
+}
+
+func serveError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(serveResult{Status: batchFailed, ExitCode: ExitUsage, Error: err.Error(), Duration: "0s"})
+}
+
+// serveStream writes the output of a command as JSON lines, flushing each of them.
+type serveStream struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	started bool
+}
+
+func (s *serveStream) writer(stream string) *streamWriter {
+	return &streamWriter{s: s, stream: stream}
+}
+
+// start sends the headers of the stream, once.
+func (s *serveStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.w.Header().Set("Content-Type", "application/x-ndjson")
+	s.w.WriteHeader(http.StatusOK)
+}
+
+func (s *serveStream) write(v any) error {
+	s.start()
+	if err := json.NewEncoder(s.w).Encode(v); err != nil {
+		return err
+	}
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// finish writes the result or, if nothing was written yet, answers errors of the request with their status code.
+func (s *serveStream) finish(res serveResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		code := 0
+		switch res.ExitCode {
+		case ExitNotFound:
+			code = http.StatusNotFound
+		case ExitUsage:
+			code = http.StatusBadRequest
+		}
+		if code != 0 {
+			s.w.Header().Set("Content-Type", "application/json")
+			s.w.WriteHeader(code)
+			_ = json.NewEncoder(s.w).Encode(res)
+			return
+		}
+	}
+	_ = s.write(res)
+}
+
+// streamWriter is the stdout or the stderr of a command run by [ServeHandler].
+type streamWriter struct {
+	s      *serveStream
+	stream string
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+	if err := w.s.write(serveEvent{Stream: w.stream, Data: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import "strings"
+
+// openAPI returns the OpenAPI document of [ServeHandler]. With a registry, every command gets its own path
+// documented with its descriptions; otherwise the document has a single path with the name as a parameter.
+func (c serveConfig) openAPI() map[string]any {
+	title := "commands"
+	paths := map[string]any{
+		"/openapi.json": map[string]any{
+			"get": map[string]any{
+				"operationId": "openapi",
+				"summary":     "this document",
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "the OpenAPI document",
+						"content":     map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object"}}},
+					},
+				},
+			},
+		},
+	}
+	if c.reg == nil {
+		paths["/commands/{name}"] = map[string]any{
+			"post": commandOperation("runCommand", "run a command", "", []any{map[string]any{
+				"name":        "name",
+				"in":          "path",
+				"required":    true,
+				"description": "name of the command",
+				"schema":      map[string]any{"type": "string"},
+			}}),
+		}
+	} else {
+		title = c.reg.path + " commands"
+		for _, e := range c.reg.entries {
+			desc := e.Long
+			if e.sub != nil {
+				names := make([]string, 0, len(e.sub.entries))
+				for _, s := range e.sub.entries {
+					names = append(names, s.Name)
+				}
+				desc = strings.TrimSpace(desc + "\n\nThe first argument is the subcommand, one of: " + strings.Join(names, ", ") + ".")
+			}
+			paths["/commands/"+e.Name] = map[string]any{
+				"post": commandOperation("run_"+nonWord.ReplaceAllString(e.Name, "_"), e.Short, desc, nil),
+			}
+		}
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": title, "version": "1.0.0"},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": map[string]any{
+				"Arguments": map[string]any{
+					"description": "the arguments of the command, like a batch entry",
+					"oneOf": []any{
+						stringArray(),
+						map[string]any{
+							"type":     "object",
+							"required": []any{"args"},
+							"properties": map[string]any{
+								"args":    stringArray(),
+								"timeout": map[string]any{"type": "string", "description": "time limit of the command", "example": "30s"},
+							},
+						},
+					},
+				},
+				"Output": map[string]any{
+					"type":     "object",
+					"required": []any{"stream", "data"},
+					"properties": map[string]any{
+						"stream": map[string]any{"type": "string", "enum": []any{"stdout", "stderr"}},
+						"data":   map[string]any{"type": "string"},
+					},
+				},
+				"Result": map[string]any{
+					"type":     "object",
+					"required": []any{"status", "exit_code", "duration"},
+					"properties": map[string]any{
+						"status":    map[string]any{"type": "string", "enum": []any{batchOK, batchFailed, batchCancelled}},
+						"exit_code": map[string]any{"type": "integer"},
+						"error":     map[string]any{"type": "string"},
+						"duration":  map[string]any{"type": "string", "example": "1.5s"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// commandOperation documents running a command.
+func commandOperation(id, summary, desc string, params []any) map[string]any {
+	op := map[string]any{
+		"operationId": id,
+		"requestBody": map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": schemaRef("Arguments")}},
+		},
+		"responses": map[string]any{
+			"200": map[string]any{
+				"description": "the output of the command followed by its result, as JSON lines",
+				"content": map[string]any{"application/x-ndjson": map[string]any{"schema": map[string]any{
+					"oneOf": []any{schemaRef("Output"), schemaRef("Result")},
+				}}},
+			},
+			"400": resultResponse("invalid arguments"),
+			"404": resultResponse("unknown command"),
+		},
+	}
+	if summary != "" {
+		op["summary"] = summary
+	}
+	if desc != "" {
+		op["description"] = desc
+	}
+	if params != nil {
+		op["parameters"] = params
+	}
+	return op
+}
+
+func resultResponse(desc string) map[string]any {
+	return map[string]any{
+		"description": desc,
+		"content":     map[string]any{"application/json": map[string]any{"schema": schemaRef("Result")}},
+	}
+}
+
+func schemaRef(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func stringArray() map[string]any {
+	return map[string]any{"type": "array", "items": map[string]any{"type": "string"}}
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/teghnet/x"
+)
+
+func TestServeHandler(t *testing.T) {
+	reg := x.NewRegistry("tool")
+	reg.Handle("echo", func(args []string) x.Command {
+		return func(ctx context.Context) error {
+			_, err := fmt.Fprintln(x.Stdout(ctx), strings.Join(args, " "))
+			return err
+		}
+	}, x.CommandShort("print the arguments"))
+	reg.Handle("fail", func([]string) x.Command {
+		return func(ctx context.Context) error {
+			_, _ = fmt.Fprint(x.Stderr(ctx), "oops")
+			return errors.New("boom")
+		}
+	})
+	reg.Handle("flags", func(args []string) x.Command {
+		return func(context.Context) error { return x.FlagsParse(args) }
+	})
+	srv := httptest.NewServer(x.ServeHandler(reg.Select, x.ServeRegistry(reg)))
+	defer srv.Close()
+
+	tests := []struct {
+		name, body string
+		code       int
+		want       []string
+	}{
+		{"echo", `["a", "b"]`, http.StatusOK, []string{
+			`{"stream":"stdout","data":"a b\n"}`,
+			`{"status":"ok","exit_code":0,"duration":`,
+		}},
+		{"echo", `{"args": ["c"], "timeout": "1s"}`, http.StatusOK, []string{
+			`{"stream":"stdout","data":"c\n"}`,
+			`{"status":"ok"`,
+		}},
+		{"fail", `[]`, http.StatusOK, []string{
+			`{"stream":"stderr","data":"oops"}`,
+			`{"status":"failed","exit_code":1,"error":"boom",`,
+		}},
+		{"nope", `[]`, http.StatusNotFound, []string{`{"status":"failed","exit_code":127,`}},
+		{"flags", `["-x"]`, http.StatusBadRequest, []string{`{"status":"failed","exit_code":2,`}},
+		{"echo", `{`, http.StatusBadRequest, []string{`{"status":"failed","exit_code":2,"error":"arguments:`}},
+	}
+	for _, tt := range tests {
+		resp, err := http.Post(srv.URL+"/commands/"+tt.name, "application/json", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.code {
+			t.Errorf("%s %s: status = %d, want %d", tt.name, tt.body, resp.StatusCode, tt.code)
+		}
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		if len(lines) != len(tt.want) {
+			t.Errorf("%s %s: got\n%s\nwant %d lines", tt.name, tt.body, b, len(tt.want))
+			continue
+		}
+		for i, want := range tt.want {
+			if !strings.HasPrefix(lines[i], want) {
+				t.Errorf("%s %s: line %d = %s, want prefix %s", tt.name, tt.body, i, lines[i], want)
+			}
+		}
+	}
+
+	for _, tt := range []struct {
+		name        string
+		contentType string
+		header      http.Header
+		host        string
+		code        int
+	}{
+		{"text/plain", "text/plain", nil, "", http.StatusUnsupportedMediaType},
+		{"no content type", "", nil, "", http.StatusUnsupportedMediaType},
+		{"origin", "application/json", http.Header{"Origin": {"https://evil.example"}}, "", http.StatusForbidden},
+		{"rebound host", "application/json", nil, "evil.example:8484", http.StatusForbidden},
+	} {
+		ran := false
+		cs := func(string, []string) x.Command {
+			return func(context.Context) error { ran = true; return nil }
+		}
+		req := httptest.NewRequest(http.MethodPost, "/commands/rm", strings.NewReader(`["-rf", "/"]`))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		for k, v := range tt.header {
+			req.Header[k] = v
+		}
+		req.Host = "127.0.0.1:8484"
+		if tt.host != "" {
+			req.Host = tt.host
+		}
+		rec := httptest.NewRecorder()
+		x.ServeHandler(cs).ServeHTTP(rec, req)
+		if rec.Code != tt.code || ran {
+			t.Errorf("%s: status = %d, ran = %v, want %d and not run", tt.name, rec.Code, ran, tt.code)
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.ClosePrint(resp.Body)
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := openapi3.NewLoader().LoadFromData(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Validate(t.Context()); err != nil {
+		t.Errorf("invalid OpenAPI document: %v", err)
+	}
+	if op := doc.Paths.Find("/commands/echo"); op == nil || op.Post == nil || op.Post.Summary != "print the arguments" {
+		t.Errorf("echo is not documented: %+v", op)
+	}
+}
+
+func TestServe_UnixSocket(t *testing.T) {
+	reg := x.NewRegistry("tool")
+	reg.Handle("ping", func([]string) x.Command {
+		return func(ctx context.Context) error {
+			_, err := fmt.Fprint(x.Stdout(ctx), "pong")
+			return err
+		}
+	})
+	sock := filepath.Join(t.TempDir(), "serve.sock")
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- x.Serve([]string{"-addr", "unix:" + sock}, reg.Select)(ctx)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	var resp *http.Response
+	var err error
+	for range 50 {
+		if resp, err = client.Post("http://tool/commands/ping", "application/json", strings.NewReader("[]")); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if !strings.HasPrefix(string(b), `{"stream":"stdout","data":"pong"}`) {
+		t.Errorf("response = %s", b)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/teghnet/x/paths"
+)
+
+// ErrInterrupted is the cancellation cause of commands interrupted by the user.
+var ErrInterrupted = errors.New("interrupted")
+
+// ShellOption sets a default of [Shell]. Command-line flags given to the shell take precedence.
+type ShellOption func(*shellConfig)
+
+// ShellPrompt sets the prompt (flag -prompt).
+func ShellPrompt(prompt string) ShellOption {
+	return func(c *shellConfig) { c.prompt = prompt }
+}
+
+// ShellHistory sets the history file (flag -history).
+func ShellHistory(name string) ShellOption {
+	return func(c *shellConfig) { c.history = name }
+}
+
+// ShellState sets where the history is kept by default. By default, the [app.App] the context was derived from is used.
+func ShellState(xdg paths.XDG) ShellOption {
+	return func(c *shellConfig) { c.state = xdg }
+}
+
+type shellConfig struct {
+	input   string
+	prompt  string
+	history string
+	state   paths.XDG
+}
+
+// ShellFlags describes the flags of [Shell], e.g. for [CommandFlags] when registering it under another name.
+func ShellFlags() []FlagOption {
+	conf := shellConfig{input: "-", prompt: "> "}
+	return conf.flags()
+}
+
+// flags declares the flags of the shell, bound to the config.
+func (c *shellConfig) flags() []FlagOption {
+	return []FlagOption{
+		Flag(&c.input, "i", "read commands from this file instead of the terminal"),
+		FlagFile("i"),
+		Flag(&c.prompt, "prompt", "prompt shown before each command"),
+		Flag(&c.history, "history", "history file (default: history under the app StatePath)"),
+		FlagFile("history"),
+	}
+}
+
+// Shell returns a Command reading commands interactively, one per line, split with [ShellSplit]
+// and dispatched through the CommandSelector. "batch" runs a [Batch] and "watch" a [Watch].
+//
+// Besides commands, the shell understands "exit" (or end of input), "history",
+// "!!" (repeat the last command) and "!n" (repeat the n-th command of the history).
+// The history is kept in the "history" file under the StatePath.
+//
+// An interrupt (Ctrl-C) cancels the running command with [ErrInterrupted] but not the shell.
+func Shell(args []string, cs CommandSelector, opts ...ShellOption) Command {
+	return func(ctx context.Context) error {
+		conf := shellConfig{input: "-", prompt: "> "}
+		for _, opt := range opts {
+			opt(&conf)
+		}
+		if err := FlagsParse(args, conf.flags()...); err != nil {
+			return err
+		}
+		if conf.history == "" {
+			if xdg, ok := appXDG(ctx, conf.state); ok {
+				conf.history = xdg.StatePath("history")
+			}
+		}
+		r, err := DynamicReader(conf.input)
+		if err != nil {
+			return err
+		}
+		defer ClosePrint(r)
+		sh := &shell{conf: conf, cs: cs, out: Stdout(ctx), errOut: Stderr(ctx)}
+		defer ClosePrint(sh)
+		if err := sh.openHistory(); err != nil {
+			return err
+		}
+		return sh.run(ctx, r)
+	}
+}
+
+type shell struct {
+	conf    shellConfig
+	cs      CommandSelector
+	out     io.Writer
+	errOut  io.Writer
+	history []string
+	hist    *os.File
+
+	mu      sync.Mutex
+	cancel  context.CancelCauseFunc
+	pending string
+}
+
+func (sh *shell) run(ctx context.Context, r io.Reader) error {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer signal.Stop(sigs)
+	interruptClaims.Add(1)
+	defer interruptClaims.Add(-1)
+	ictx, stop := context.WithCancel(ctx)
+	defer stop()
+	go sh.interrupts(ictx, sigs)
+
+	sc := bufio.NewScanner(r)
+	for {
+		line, args, ok := sh.read(sc)
+		if !ok {
+			_, _ = fmt.Fprintln(sh.out)
+			return sc.Err()
+		}
+		if err := ctx.Err(); err != nil {
+			return context.Cause(ctx)
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if strings.HasPrefix(args[0], "!") && len(args) == 1 {
+			if line, args, ok = sh.recall(args[0]); !ok {
+				continue
+			}
+			_, _ = fmt.Fprintln(sh.out, line)
+		}
+		if args[0] == "exit" || args[0] == "quit" {
+			return nil
+		}
+		sh.remember(line)
+		if err := sh.exec(ctx, args); err != nil {
+			_, _ = fmt.Fprintf(sh.errOut, "error: %v\n", err)
+		}
+	}
+}
+
+// read returns the next command, reading more lines while quotes or escapes are open.
+func (sh *shell) read(sc *bufio.Scanner) (string, []string, bool) {
+	prompt := sh.conf.prompt
+	var line string
+	for {
+		sh.prompt(prompt)
+		if !sc.Scan() {
+			return "", nil, false
+		}
+		if line != "" {
+			line += "\n"
+		}
+		line += sc.Text()
+		args, err := ShellSplit(line)
+		if errors.Is(err, ErrUnterminated) {
+			prompt = "... "
+			continue
+		}
+		sh.prompt("")
+		if err != nil {
+			_, _ = fmt.Fprintf(sh.errOut, "error: %v\n", err)
+			return line, nil, true
+		}
+		return line, args, true
+	}
+}
+
+// prompt shows the prompt and remembers it, so it can be shown again after an interrupt.
+func (sh *shell) prompt(p string) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.pending = p
+	if p != "" {
+		_, _ = fmt.Fprint(sh.out, p)
+	}
+}
+
+func (sh *shell) exec(ctx context.Context, args []string) error {
+	var cmd Command
+	switch args[0] {
+	case "history":
+		for i, h := range sh.history {
+			_, _ = fmt.Fprintf(sh.out, "%5d  %s\n", i+1, h)
+		}
+		return nil
+	case "shell":
+		return errors.New("already in a shell")
+	case "batch":
+		cmd = Batch(args[1:], sh.cs)
+	case "watch":
+		cmd = Watch(args[1:], sh.cs)
+	default:
+		cmd = selectCommand(sh.cs, args[0], args[1:])
+	}
+	cctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	sh.mu.Lock()
+	sh.cancel = cancel
+	sh.mu.Unlock()
+	defer func() {
+		sh.mu.Lock()
+		sh.cancel = nil
+		sh.mu.Unlock()
+	}()
+	return cmd(cctx)
+}
+
+// interrupts cancels the running command on every signal or, at the prompt, shows a fresh prompt.
+func (sh *shell) interrupts(ctx context.Context, sigs <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigs:
+			sh.mu.Lock()
+			if sh.cancel != nil {
+				sh.cancel(ErrInterrupted)
+			} else if sh.pending != "" {
+				_, _ = fmt.Fprint(sh.out, "\n", sh.pending)
+			}
+			sh.mu.Unlock()
+		}
+	}
+}
+
+// recall returns the history entry referred to by "!!" or "!n".
+func (sh *shell) recall(ref string) (string, []string, bool) {
+	n := len(sh.history)
+	if ref != "!!" {
+		var err error
+		if n, err = strconv.Atoi(ref[1:]); err != nil {
+			return "", []string{ref}, true
+		}
+	}
+	if n < 1 || n > len(sh.history) {
+		_, _ = fmt.Fprintf(sh.errOut, "error: %s: event not found\n", ref)
+		return "", nil, false
+	}
+	line := sh.history[n-1]
+	args, err := ShellSplit(line)
+	if err != nil {
+		_, _ = fmt.Fprintf(sh.errOut, "error: %v\n", err)
+		return "", nil, false
+	}
+	return line, args, true
+}
+
+// openHistory loads the history file and keeps it open for appending.
+func (sh *shell) openHistory() error {
+	if sh.conf.history == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(sh.conf.history), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(sh.conf.history, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	sh.hist = f
+	// every line is a JSON string, so commands spanning lines survive
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var line string
+		if err := dec.Decode(&line); err != nil {
+			return fmt.Errorf("history %s: %w", sh.conf.history, err)
+		}
+		sh.history = append(sh.history, line)
+	}
+	return nil
+}
+
+func (sh *shell) remember(line string) {
+	sh.history = append(sh.history, line)
+	if sh.hist == nil {
+		return
+	}
+	if err := json.NewEncoder(sh.hist).Encode(line); err != nil {
+		_, _ = fmt.Fprintf(sh.errOut, "error: could not save history: %v\n", err)
+	}
+}
+
+// Close implements [io.Closer].
+func (sh *shell) Close() error {
+	if sh.hist == nil {
+		return nil
+	}
+	return sh.hist.Close()
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/teghnet/x"
+)
+
+func TestShell(t *testing.T) {
+	var got [][]string
+	cs := func(name string, args []string) x.Command {
+		return func(context.Context) error {
+			got = append(got, append([]string{name}, args...))
+			if name == "fail" {
+				return errors.New("boom")
+			}
+			return nil
+		}
+	}
+	history := filepath.Join(t.TempDir(), "history")
+	in := writeBatch(t, "session.txt", `convert -i "a b.xml"
+fail
+echo 'multi
+line'
+!1
+exit
+never
+`)
+	var out, errOut bytes.Buffer
+	ctx := x.WithStderr(x.WithStdout(t.Context(), &out), &errOut)
+	if err := x.Shell([]string{"-i", in, "-history", history, "-prompt", ""}, cs)(ctx); err != nil {
+		t.Fatalf("Shell() error = %v", err)
+	}
+	want := [][]string{
+		{"convert", "-i", "a b.xml"},
+		{"fail"},
+		{"echo", "multi\nline"},
+		{"convert", "-i", "a b.xml"},
+	}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("Shell() ran %q, want %q", got, want)
+	}
+	if !strings.Contains(errOut.String(), "error: boom") {
+		t.Errorf("Shell() stderr = %q, want the error of fail", errOut.String())
+	}
+
+	// the history survives the session
+	in = writeBatch(t, "history.txt", "history\n")
+	out.Reset()
+	if err := x.Shell([]string{"-i", in, "-history", history, "-prompt", ""}, cs)(ctx); err != nil {
+		t.Fatalf("Shell() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "    3  echo 'multi\nline'\n") || !strings.Contains(out.String(), "    4  convert") {
+		t.Errorf("Shell() history = %q", out.String())
+	}
+}
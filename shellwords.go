@@ -0,0 +1,109 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrUnterminated is returned by [ShellSplit] when the input ends inside quotes or after a backslash.
+// Callers reading line by line can append the next line and try again.
+var ErrUnterminated = errors.New("unterminated quote or escape")
+
+// ShellSplit splits a command line into arguments following POSIX shell quoting rules,
+// without performing any expansions:
+//   - unquoted whitespace separates arguments,
+//   - single quotes preserve everything literally,
+//   - double quotes preserve everything but \", \\, \$, \` and \<newline>,
+//   - an unquoted backslash escapes the next character, a backslash before a newline joins the lines,
+//   - an unquoted # at the start of an argument starts a comment running to the end of the line.
+func ShellSplit(line string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	rs := []rune(line)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case r == '#' && !inArg:
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+		case r == '\\':
+			i++
+			if i == len(rs) {
+				return nil, ErrUnterminated
+			}
+			if rs[i] != '\n' {
+				arg.WriteRune(rs[i])
+				inArg = true
+			}
+		case r == '\'':
+			end := indexRune(rs, i+1, '\'')
+			if end < 0 {
+				return nil, ErrUnterminated
+			}
+			arg.WriteString(string(rs[i+1 : end]))
+			inArg = true
+			i = end
+		case r == '"':
+			i++
+			for ; i < len(rs) && rs[i] != '"'; i++ {
+				if rs[i] == '\\' && i+1 < len(rs) && strings.ContainsRune("\"\\$`\n", rs[i+1]) {
+					i++
+					if rs[i] == '\n' {
+						continue
+					}
+				}
+				arg.WriteRune(rs[i])
+			}
+			if i == len(rs) {
+				return nil, ErrUnterminated
+			}
+			inArg = true
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+func indexRune(rs []rune, from int, r rune) int {
+	for i := from; i < len(rs); i++ {
+		if rs[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// ShellJoin is the inverse of [ShellSplit]: it joins the arguments into a command line,
+// single-quoting those that would otherwise be split or interpreted.
+func ShellJoin(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !strings.ContainsRune("-_./:=,+@%", r) && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9')
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/teghnet/x"
+)
+
+func TestShellSplit(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr error
+	}{
+		{`convert -i "a b.xml" -o out.json`, []string{"convert", "-i", "a b.xml", "-o", "out.json"}, nil},
+		{`echo 'it''s' "say \"hi\"" a\ b`, []string{"echo", "its", `say "hi"`, "a b"}, nil},
+		{`echo '\n' "\n" \$HOME`, []string{"echo", `\n`, `\n`, "$HOME"}, nil},
+		{`echo "" ''`, []string{"echo", "", ""}, nil},
+		{`echo a#b # comment`, []string{"echo", "a#b"}, nil},
+		{"  # only a comment", nil, nil},
+		{"echo a \\\n b", []string{"echo", "a", "b"}, nil},
+		{`echo "open`, nil, x.ErrUnterminated},
+		{`echo 'open`, nil, x.ErrUnterminated},
+		{`echo \`, nil, x.ErrUnterminated},
+	}
+	for _, tt := range tests {
+		got, err := x.ShellSplit(tt.line)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("ShellSplit(%q) error = %v, want %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ShellSplit(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+		if tt.wantErr != nil {
+			continue
+		}
+		joined := x.ShellJoin(tt.want...)
+		if again, err := x.ShellSplit(joined); err != nil || !slices.Equal(again, tt.want) {
+			t.Errorf("ShellSplit(ShellJoin(%q)) = %q, %v", tt.want, again, err)
+		}
+	}
+}
+
+func TestBatch_ShellFormat(t *testing.T) {
+	var got [][]string
+	cs := func(name string, args []string) x.Command {
+		return func(context.Context) error {
+			got = append(got, append([]string{name}, args...))
+			return nil
+		}
+	}
+	in := writeBatch(t, "b.sh", `# convert all the things
+convert -i "a b.xml" -o out.json
+
+convert -i 'multi
+line.xml' \
+	-o out2.json
+`)
+	if err := x.Batch([]string{"-i", in}, cs)(t.Context()); err != nil {
+		t.Fatalf("Batch() error = %v", err)
+	}
+	want := [][]string{
+		{"convert", "-i", "a b.xml", "-o", "out.json"},
+		{"convert", "-i", "multi\nline.xml", "-o", "out2.json"},
+	}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("Batch() ran %q, want %q", got, want)
+	}
+}
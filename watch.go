@@ -0,0 +1,217 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"charm.land/log/v2"
+	"github.com/fsnotify/fsnotify"
+	"github.com/teghnet/x/fsio"
+)
+
+// ErrFilesChanged is the cancellation cause of a run of [Watch] superseded by a change of the files.
+var ErrFilesChanged = errors.New("files changed")
+
+// WatchOption sets a default of [Watch]. Command-line flags given to the watch take precedence.
+type WatchOption func(*watchConfig)
+
+// WatchPatterns adds glob patterns of the watched files (flag -p).
+func WatchPatterns(patterns ...string) WatchOption {
+	return func(c *watchConfig) { c.patterns = append(c.patterns, patterns...) }
+}
+
+// WatchDir sets the directory the patterns are relative to (flag -dir).
+func WatchDir(dir string) WatchOption {
+	return func(c *watchConfig) { c.dir = dir }
+}
+
+// WatchDebounce sets how long the files must stay unchanged before the command is run again (flag -debounce).
+func WatchDebounce(d time.Duration) WatchOption {
+	return func(c *watchConfig) { c.debounce = d }
+}
+
+type watchConfig struct {
+	patterns []string
+	dir      string
+	debounce time.Duration
+}
+
+// WatchFlags describes the flags of [Watch], e.g. for [CommandFlags] when registering it under another name.
+func WatchFlags() []FlagOption {
+	conf := watchConfig{dir: ".", debounce: 200 * time.Millisecond}
+	return conf.flags()
+}
+
+// flags declares the flags of the watch, bound to the config.
+func (c *watchConfig) flags() []FlagOption {
+	return []FlagOption{
+		Flag(&c.patterns, "p", "glob pattern of the watched files, relative to -dir (repeatable)"),
+		FlagFile("p"),
+		Flag(&c.dir, "dir", "directory of the watched files"),
+		FlagFile("dir"),
+		Flag(&c.debounce, "debounce", "time without changes before the command runs again"),
+	}
+}
+
+// Watch returns a Command running the command given by the arguments left after the flags,
+// e.g. `watch -p '*.go' -p 'testdata/*.json' batch -i jobs.jsonl`, and running it again whenever
+// files matching the patterns (see [fsio.Glob]) are created, written, removed or renamed.
+//
+// Changes are debounced. A change cancels the context of the command still running with [ErrFilesChanged];
+// the next run starts once the cancelled one has returned. Errors of the runs are printed, not returned:
+// the watch ends only when its context is cancelled.
+func Watch(args []string, cs CommandSelector, opts ...WatchOption) Command {
+	return func(ctx context.Context) error {
+		conf := watchConfig{dir: ".", debounce: 200 * time.Millisecond}
+		for _, opt := range opts {
+			opt(&conf)
+		}
+		fs := flagSet(append(conf.flags(), FlagSetErrorHandling(flag.ContinueOnError))...)
+		if err := parseFlags(fs, args); err != nil {
+			return err
+		}
+		cmdArgs := fs.Args()
+		switch {
+		case len(conf.patterns) == 0:
+			return &UsageError{Err: errors.New("watch: no patterns given")}
+		case len(cmdArgs) == 0:
+			return &UsageError{Err: errors.New("watch: no command given")}
+		case cmdArgs[0] == "watch":
+			return errors.New("watch: already watching")
+		}
+		for _, p := range conf.patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return &UsageError{Err: fmt.Errorf("watch: pattern %q: %w", p, err)}
+			}
+		}
+
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+		defer ClosePrint(w)
+		wt := &watcher{conf: conf, w: w, watched: make(map[string]bool)}
+		if err := wt.rewatch(); err != nil {
+			return err
+		}
+		return wt.run(ctx, func() Command { return BatchOnce(cmdArgs, cs) })
+	}
+}
+
+type watcher struct {
+	conf    watchConfig
+	w       *fsnotify.Watcher
+	watched map[string]bool
+}
+
+func (wt *watcher) run(ctx context.Context, cmd func() Command) error {
+	var (
+		cancel  context.CancelCauseFunc
+		done    chan error
+		pending bool // a run is due once the previous has returned
+	)
+	timer := time.NewTimer(0) // the first run
+	defer timer.Stop()
+	defer func() {
+		if cancel != nil {
+			cancel(context.Cause(ctx))
+			<-done
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case err := <-wt.w.Errors:
+			return fmt.Errorf("watch: %w", err)
+		case ev := <-wt.w.Events:
+			if err := wt.rewatch(); err != nil {
+				return err
+			}
+			if !wt.matches(ev.Name) {
+				continue
+			}
+			log.Debug("watch: file changed", "name", ev.Name, "op", ev.Op)
+			if cancel != nil {
+				cancel(ErrFilesChanged)
+			}
+			pending = false
+			timer.Reset(wt.conf.debounce)
+		case <-timer.C:
+			pending = true
+		case err := <-done:
+			cancel(nil)
+			cancel, done = nil, nil
+			if err != nil && !errors.Is(err, ErrFilesChanged) {
+				_, _ = fmt.Fprintf(Stderr(ctx), "error: %v\n", err)
+			}
+		}
+		if pending && done == nil {
+			pending = false
+			rctx, rcancel := context.WithCancelCause(ctx)
+			cancel, done = rcancel, make(chan error, 1)
+			go func(cmd Command, done chan<- error) {
+				done <- CancelCause()(cmd)(rctx)
+			}(cmd(), done)
+		}
+	}
+}
+
+// rewatch watches the directories of the files matching the patterns and the fixed directories of the patterns,
+// so new files are noticed too.
+func (wt *watcher) rewatch() error {
+	dirs := make(map[string]bool)
+	for _, p := range wt.conf.patterns {
+		dirs[globBase(p)] = true
+		for name := range fsio.Glob(os.DirFS(wt.conf.dir), p) {
+			dirs[path.Dir(name)] = true
+		}
+	}
+	for dir := range dirs {
+		if wt.watched[dir] {
+			continue
+		}
+		if err := wt.w.Add(filepath.Join(wt.conf.dir, filepath.FromSlash(dir))); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("watch: %w", err)
+		}
+		wt.watched[dir] = true
+	}
+	return nil
+}
+
+// matches reports whether the file of an event matches any of the patterns.
+func (wt *watcher) matches(name string) bool {
+	rel, err := filepath.Rel(wt.conf.dir, name)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, p := range wt.conf.patterns {
+		if ok, _ := path.Match(p, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// globBase returns the leading directories of the pattern without any wildcards.
+func globBase(pattern string) string {
+	dir := path.Dir(pattern)
+	for strings.ContainsAny(dir, `*?[\`) {
+		dir = path.Dir(dir)
+	}
+	return dir
+}
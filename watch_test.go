@@ -0,0 +1,76 @@
+// Copyright (c) 2026 Paweł Zaremba
+// SPDX-License-Identifier: MIT
+
+package x_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/teghnet/x"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.txt")
+
+	runs := make(chan []string, 10)
+	causes := make(chan error, 10)
+	cs := func(name string, args []string) x.Command {
+		return func(ctx context.Context) error {
+			runs <- append([]string{name}, args...)
+			if name == "slow" {
+				<-ctx.Done()
+				causes <- context.Cause(ctx)
+				return ctx.Err()
+			}
+			return nil
+		}
+	}
+	wait := func(what string) []string {
+		t.Helper()
+		select {
+		case r := <-runs:
+			return r
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", what)
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- x.Watch([]string{"-dir", dir, "-p", "*.txt", "-debounce", "20ms", "slow", "arg"}, cs)(ctx)
+	}()
+	if got := wait("first run"); len(got) != 2 || got[1] != "arg" {
+		t.Errorf("first run = %q", got)
+	}
+
+	write("ignored.log")
+	write("b.txt")
+	wait("run after change")
+	if cause := <-causes; !errors.Is(cause, x.ErrFilesChanged) {
+		t.Errorf("cancellation cause = %v, want ErrFilesChanged", cause)
+	}
+	select {
+	case r := <-runs:
+		t.Errorf("unexpected run %q", r)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}